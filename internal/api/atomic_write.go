@@ -0,0 +1,109 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// writeFileAtomic replaces path with data without ever leaving a partially
+// written file behind. The data is written to a temp file in the same
+// directory, synced, and renamed over the original. An existing file keeps
+// its mode; new files use perm. Temp files start with "._" so the tree,
+// search, and task walkers ignore them if a crash leaves one behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "._"+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	committed = true
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes a directory entry update to disk. Not every platform
+// supports syncing directories, so failures are ignored.
+func syncDir(dir string) {
+	handle, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = handle.Sync()
+	_ = handle.Close()
+}
+
+// noteLocks serializes the writers of each note, so a read, check, and write
+// of one note cannot interleave with another writer of the same note. An
+// entry lives only while a writer holds or waits for it.
+type noteLocks struct {
+	mu    sync.Mutex
+	locks map[string]*noteLock
+}
+
+type noteLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+// lockNote locks the note at relPath for writing and returns the function
+// that unlocks it. Hold it from the read the write is based on until the
+// write is done.
+func (s *Server) lockNote(relPath string) func() {
+	locks := &s.noteLocks
+	locks.mu.Lock()
+	if locks.locks == nil {
+		locks.locks = make(map[string]*noteLock)
+	}
+	lock, ok := locks.locks[relPath]
+	if !ok {
+		lock = &noteLock{}
+		locks.locks[relPath] = lock
+	}
+	lock.waiters++
+	locks.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		locks.mu.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(locks.locks, relPath)
+		}
+		locks.mu.Unlock()
+	}
+}
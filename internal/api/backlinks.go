@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const snippetRadius = 60
+
+type Backlink struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	LineNumber int    `json:"lineNumber"`
+	Kind       string `json:"kind"`
+	Snippet    string `json:"snippet"`
+}
+
+func (s *Server) handleBacklinks(w http.ResponseWriter, r *http.Request) {
+	_, relPath, ok := s.resolveNoteParam(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+
+	index, err := s.buildLinkIndex()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to index notes")
+		return
+	}
+
+	backlinks := make([]Backlink, 0)
+	err = s.walkNotes(func(rel string, data []byte) error {
+		if rel == relPath {
+			return nil
+		}
+		for _, link := range extractNoteLinks(string(data)) {
+			if index.resolveLink(rel, link) != relPath {
+				continue
+			}
+			backlinks = append(backlinks, Backlink{
+				Path:       rel,
+				Name:       filepath.Base(rel),
+				LineNumber: link.LineNumber,
+				Kind:       link.Kind,
+				Snippet:    lineSnippet(link.Line, link.Start, link.End),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load backlinks")
+		return
+	}
+
+	sort.SliceStable(backlinks, func(i, j int) bool {
+		if backlinks[i].Path == backlinks[j].Path {
+			return backlinks[i].LineNumber < backlinks[j].LineNumber
+		}
+		return backlinks[i].Path < backlinks[j].Path
+	})
+	writeJSON(w, http.StatusOK, backlinks)
+}
+
+// walkNotes calls fn with the notes-relative path and contents of every
+// markdown note, skipping ignored files and app-managed folders. Notes that
+// cannot be read are skipped.
+func (s *Server) walkNotes(fn func(rel string, data []byte) error) error {
+	return filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if s.isReservedDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isIgnoredFile(d.Name()) || !isMarkdown(d.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.notesDir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		return fn(filepath.ToSlash(rel), data)
+	})
+}
+
+// lineSnippet trims line to the text around the byte range [start, end),
+// adding ellipses where it was cut.
+func lineSnippet(line string, start, end int) string {
+	from := start - snippetRadius
+	to := end + snippetRadius
+	prefix, suffix := "", ""
+	if from <= 0 {
+		from = 0
+	} else {
+		for from < start && !utf8.RuneStart(line[from]) {
+			from++
+		}
+		prefix = "…"
+	}
+	if to >= len(line) {
+		to = len(line)
+	} else {
+		for to > end && !utf8.RuneStart(line[to]) {
+			to--
+		}
+		suffix = "…"
+	}
+	return prefix + strings.TrimSpace(line[from:to]) + suffix
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"strings"
+	"time"
+)
+
+type NoteConflictResponse struct {
+	Error         string       `json:"error"`
+	Path          string       `json:"path"`
+	BaseHash      string       `json:"baseHash"`
+	ServerHash    string       `json:"serverHash"`
+	ServerContent string       `json:"serverContent"`
+	Modified      time.Time    `json:"modified"`
+	Diff          NoteDiffHint `json:"diff"`
+}
+
+// NoteDiffHint locates a conflict. StartLine, ServerEndLine, and
+// ClientEndLine mark the single region where the server copy and the
+// submitted copy diverge, after trimming the lines both share at the start
+// and end. When the revision the client edited is still in the note's
+// history, Base is true and Server and Client give the region of that base
+// each side changed, so the client can tell its edit apart from the other
+// one; Overlap reports whether the two regions touch, in which case the
+// edits cannot be merged line by line. Line numbers are 1-based and
+// inclusive; an end line smaller than the start line means that side has no
+// lines in the region.
+type NoteDiffHint struct {
+	StartLine     int              `json:"startLine"`
+	ServerEndLine int              `json:"serverEndLine"`
+	ClientEndLine int              `json:"clientEndLine"`
+	Base          bool             `json:"base"`
+	Server        *NoteChangeRange `json:"server,omitempty"`
+	Client        *NoteChangeRange `json:"client,omitempty"`
+	Overlap       bool             `json:"overlap,omitempty"`
+}
+
+// NoteChangeRange is the region of the base revision one side changed: base
+// lines BaseStartLine to BaseEndLine became lines StartLine to EndLine.
+type NoteChangeRange struct {
+	BaseStartLine int `json:"baseStartLine"`
+	BaseEndLine   int `json:"baseEndLine"`
+	StartLine     int `json:"startLine"`
+	EndLine       int `json:"endLine"`
+}
+
+func noteHash(data []byte) string {
+	return hashLine(string(data))
+}
+
+func formatETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// expectedNoteHash returns the hash the client based its edit on, taken from
+// the If-Match header when present and from the payload otherwise. An empty
+// result means the client did not ask for a concurrency check.
+func expectedNoteHash(ifMatch, baseHash string) string {
+	value := strings.TrimSpace(ifMatch)
+	if value == "" {
+		return strings.TrimSpace(baseHash)
+	}
+	value = strings.TrimPrefix(value, "W/")
+	return strings.Trim(value, `"`)
+}
+
+// noteDiffHint compares the server and client copies and, when base is not
+// nil, each of them with the base revision the client started from.
+func noteDiffHint(base *string, server, client string) NoteDiffHint {
+	serverLines := strings.Split(server, "\n")
+	clientLines := strings.Split(client, "\n")
+	prefix, suffix := sharedLines(serverLines, clientLines)
+	hint := NoteDiffHint{
+		StartLine:     prefix + 1,
+		ServerEndLine: len(serverLines) - suffix,
+		ClientEndLine: len(clientLines) - suffix,
+	}
+	if base == nil {
+		return hint
+	}
+
+	baseLines := strings.Split(*base, "\n")
+	hint.Base = true
+	hint.Server = changeRange(baseLines, serverLines)
+	hint.Client = changeRange(baseLines, clientLines)
+	// Edits next to each other count as overlapping, as they do for diff3.
+	hint.Overlap = hint.Server.BaseStartLine <= hint.Client.BaseEndLine+1 &&
+		hint.Client.BaseStartLine <= hint.Server.BaseEndLine+1
+	return hint
+}
+
+// changeRange returns the region of base that changed lines replaced.
+func changeRange(base, changed []string) *NoteChangeRange {
+	prefix, suffix := sharedLines(base, changed)
+	return &NoteChangeRange{
+		BaseStartLine: prefix + 1,
+		BaseEndLine:   len(base) - suffix,
+		StartLine:     prefix + 1,
+		EndLine:       len(changed) - suffix,
+	}
+}
+
+// sharedLines counts the lines a and b share at the start and, of the rest,
+// at the end.
+func sharedLines(a, b []string) (int, int) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return prefix, suffix
+}
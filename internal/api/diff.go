@@ -0,0 +1,217 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	// diffMaxEdits caps the edit distance searched for. The trace kept for the
+	// walk back grows with its square, so larger changes fall back to
+	// replacing the whole changed block.
+	diffMaxEdits = 2000
+)
+
+type diffOp struct {
+	kind byte // ' ' unchanged, '-' removed, '+' added
+	text string
+	aIdx int
+	bIdx int
+}
+
+// unifiedDiff renders a unified diff of two texts, compared line by line.
+// It returns an empty string when the texts are identical.
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	ops := diffLines(strings.Split(from, "\n"), strings.Split(to, "\n"))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	i := 0
+	for i < len(ops) {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContextLines {
+				break
+			}
+			end = next
+		}
+		stop := end + diffContextLines
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		writeDiffHunk(&out, ops[start:stop])
+		i = stop
+	}
+	return out.String()
+}
+
+func writeDiffHunk(out *strings.Builder, ops []diffOp) {
+	aStart, bStart := -1, -1
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if aStart < 0 {
+				aStart = op.aIdx
+			}
+			aCount++
+		}
+		if op.kind != '-' {
+			if bStart < 0 {
+				bStart = op.bIdx
+			}
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount, ops[0].aIdx), hunkRange(bStart, bCount, ops[0].bIdx))
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		out.WriteByte('\n')
+	}
+}
+
+func hunkRange(start, count, fallback int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", fallback)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a shortest edit script between a and b using Myers'
+// algorithm. Each op records the position of its line in a and b; for
+// insertions aIdx is the index of the next line in a, and vice versa.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', text: a[i], aIdx: i, bIdx: i})
+	}
+	for _, op := range myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		op.aIdx += prefix
+		op.bIdx += prefix
+		ops = append(ops, op)
+	}
+	for i := 0; i < suffix; i++ {
+		ai := len(a) - suffix + i
+		bi := len(b) - suffix + i
+		ops = append(ops, diffOp{kind: ' ', text: a[ai], aIdx: ai, bIdx: bi})
+	}
+	return ops
+}
+
+// myersDiff returns the edit script for a and b. Each step of the search keeps
+// only the diagonals it can reach (-d..d) for the walk back. When no script of
+// at most diffMaxEdits edits exists it removes all of a and adds all of b.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	bound := n + m
+	offset := bound + 1
+	v := make([]int, 2*bound+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= bound && !found; d++ {
+		if d > diffMaxEdits {
+			return replaceAllDiff(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var reversed []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d] holds diagonals -d..d, so diagonal k is at k+d.
+		prev := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffOp{kind: ' ', text: a[x-1], aIdx: x - 1, bIdx: y - 1})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, diffOp{kind: '+', text: b[y-1], aIdx: x, bIdx: y - 1})
+		} else {
+			reversed = append(reversed, diffOp{kind: '-', text: a[x-1], aIdx: x - 1, bIdx: y})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, diffOp{kind: ' ', text: a[x-1], aIdx: x - 1, bIdx: y - 1})
+		x--
+		y--
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// replaceAllDiff is the edit script that removes every line of a and then adds
+// every line of b.
+func replaceAllDiff(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, line := range a {
+		ops = append(ops, diffOp{kind: '-', text: line, aIdx: i, bIdx: 0})
+	}
+	for j, line := range b {
+		ops = append(ops, diffOp{kind: '+', text: line, aIdx: len(a), bIdx: j})
+	}
+	return ops
+}
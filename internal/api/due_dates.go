@@ -0,0 +1,129 @@
+package api
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var relativeDuePattern = regexp.MustCompile(`^([+-]\d{1,4})([dwmy])$`)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// dueDateBase is the day relative due dates in a note count from: the date in
+// a daily note's name (Daily/2025-03-10.md), otherwise today.
+func dueDateBase(relPath string) time.Time {
+	name := strings.TrimSuffix(path.Base(relPath), path.Ext(relPath))
+	if parsed, err := time.ParseInLocation("2006-01-02", name, time.Local); err == nil {
+		return parsed
+	}
+	return timeNow()
+}
+
+// resolveRelativeDate turns a relative due date (today, tomorrow, yesterday,
+// a weekday, next-week, next-month, or an offset like +3d, -1w, +2m, +1y)
+// into a day counted from base.
+func resolveRelativeDate(raw string, base time.Time) (time.Time, bool) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	day := time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, time.Local)
+	switch value {
+	case "today":
+		return day, true
+	case "tomorrow":
+		return day.AddDate(0, 0, 1), true
+	case "yesterday":
+		return day.AddDate(0, 0, -1), true
+	case "next-week":
+		return nextWeekday(day, time.Monday), true
+	case "next-month":
+		return time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, time.Local), true
+	}
+	if weekday, ok := weekdayNames[value]; ok {
+		return nextWeekday(day, weekday), true
+	}
+	match := relativeDuePattern.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, false
+	}
+	count, err := strconv.Atoi(match[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch match[2] {
+	case "d":
+		return day.AddDate(0, 0, count), true
+	case "w":
+		return day.AddDate(0, 0, 7*count), true
+	case "m":
+		return addMonths(day, count), true
+	default:
+		return addMonths(day, 12*count), true
+	}
+}
+
+// nextWeekday returns the first weekday after day, one to seven days later.
+func nextWeekday(day time.Time, weekday time.Weekday) time.Time {
+	days := (int(weekday) - int(day.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return day.AddDate(0, 0, days)
+}
+
+// addMonths moves day by months, keeping the day of the month but stopping at
+// the end of shorter months (Jan 31 + 1 month is Feb 28).
+func addMonths(day time.Time, months int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(months), 1, 0, 0, 0, 0, time.Local)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(day.Day(), last)-1)
+}
+
+// resolveRelativeDueDates rewrites the relative due date of each task line in
+// content to an ISO date and returns how many changed. Fenced code blocks are
+// left as written.
+func resolveRelativeDueDates(content string, base time.Time) (string, int) {
+	lines := strings.Split(content, "\n")
+	count := 0
+	var fence codeFence
+	for i, line := range lines {
+		if fence.skip(line) {
+			continue
+		}
+		loc := todoLinePattern.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		rest := line[loc[1]:]
+		match := taskDuePattern.FindStringSubmatchIndex(rest)
+		if match == nil {
+			continue
+		}
+		// Trailing punctuation is not part of the date and stays in place.
+		start := loc[1] + match[4]
+		end := start + len(extractDueDate(rest))
+		resolved, ok := resolveRelativeDate(line[start:end], base)
+		if !ok {
+			continue
+		}
+		lines[i] = line[:start] + resolved.Format("2006-01-02") + line[end:]
+		count++
+	}
+	return strings.Join(lines, "\n"), count
+}
+
+// rewritesRelativeDates reports whether saves should turn relative due dates
+// into ISO dates.
+func (s *Server) rewritesRelativeDates() bool {
+	settings, _, err := s.loadSettings()
+	return err == nil && settings.RewriteRelativeDates
+}
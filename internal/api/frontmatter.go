@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontmatterDelimiter = "---"
+
+type FrontmatterPayload struct {
+	Path     string         `json:"path"`
+	Set      map[string]any `json:"set,omitempty"`
+	Remove   []string       `json:"remove,omitempty"`
+	BaseHash string         `json:"baseHash,omitempty"`
+}
+
+type FrontmatterResponse struct {
+	Path        string         `json:"path"`
+	Hash        string         `json:"hash"`
+	Frontmatter map[string]any `json:"frontmatter"`
+}
+
+func (s *Server) handleUpdateFrontmatter(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[FrontmatterPayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(payload.Set) == 0 && len(payload.Remove) == 0 {
+		writeError(w, http.StatusBadRequest, "set or remove is required")
+		return
+	}
+	absPath, relPath, ok := s.resolveNoteParam(w, payload.Path)
+	if !ok {
+		return
+	}
+	if !isMarkdown(absPath) {
+		writeError(w, http.StatusBadRequest, "not a note file")
+		return
+	}
+
+	defer s.lockNote(relPath)()
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return
+	}
+	expected := expectedNoteHash(r.Header.Get("If-Match"), payload.BaseHash)
+	if expected != "" && expected != "*" && expected != noteHash(current) {
+		writeError(w, http.StatusConflict, "note changed on the server")
+		return
+	}
+
+	updated, err := updateFrontmatter(string(current), payload.Set, payload.Remove)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if updated != string(current) {
+		s.snapshotNote(relPath, current, "frontmatter")
+		if err := writeFileAtomic(absPath, []byte(updated), 0o644); err != nil {
+			s.logger.Error("unable to update frontmatter", "path", relPath, "error", err)
+			writeError(w, http.StatusInternalServerError, "unable to update note")
+			return
+		}
+		s.indexPath(relPath)
+	}
+
+	frontmatter, _ := parseFrontmatter(updated)
+	if frontmatter == nil {
+		frontmatter = map[string]any{}
+	}
+	hash := noteHash([]byte(updated))
+	s.logger.Info("frontmatter updated", "path", relPath, "set", len(payload.Set), "remove", len(payload.Remove))
+	w.Header().Set("ETag", formatETag(hash))
+	writeJSON(w, http.StatusOK, FrontmatterResponse{Path: relPath, Hash: hash, Frontmatter: frontmatter})
+}
+
+// splitFrontmatter separates a leading "---" delimited block from the note
+// body. The returned block excludes the delimiter lines; body is everything
+// after the closing delimiter line.
+func splitFrontmatter(content string) (string, string, bool) {
+	firstEnd := strings.IndexByte(content, '\n')
+	if firstEnd == -1 || strings.TrimRight(content[:firstEnd], " \t\r") != frontmatterDelimiter {
+		return "", content, false
+	}
+	offset := firstEnd + 1
+	for offset <= len(content) {
+		lineEnd := strings.IndexByte(content[offset:], '\n')
+		var line string
+		next := len(content)
+		if lineEnd == -1 {
+			line = content[offset:]
+		} else {
+			line = content[offset : offset+lineEnd]
+			next = offset + lineEnd + 1
+		}
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == frontmatterDelimiter || trimmed == "..." {
+			return content[firstEnd+1 : offset], content[next:], true
+		}
+		if lineEnd == -1 {
+			break
+		}
+		offset = next
+	}
+	return "", content, false
+}
+
+// parseFrontmatter decodes the YAML frontmatter of a note. It returns nil
+// without error when the note has none.
+func parseFrontmatter(content string) (map[string]any, error) {
+	block, _, ok := splitFrontmatter(content)
+	if !ok {
+		return nil, nil
+	}
+	var raw any
+	if err := yaml.Unmarshal([]byte(block), &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return map[string]any{}, nil
+	}
+	values, ok := normalizeYAML(raw).(map[string]any)
+	if !ok {
+		return nil, errors.New("frontmatter must be a mapping")
+	}
+	return values, nil
+}
+
+// normalizeYAML converts maps with non-string keys so the value can be
+// encoded as JSON.
+func normalizeYAML(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// frontmatterList reads a key that may hold a YAML list or a comma or space
+// separated string, as used for tags and aliases.
+func frontmatterList(frontmatter map[string]any, key string) []string {
+	var values []string
+	switch v := frontmatter[key].(type) {
+	case string:
+		values = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || (key == "tags" && r == ' ') })
+	case []any:
+		for _, item := range v {
+			if item == nil {
+				continue
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+	}
+
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if key == "tags" {
+			value = strings.TrimPrefix(value, "#")
+		}
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result
+}
+
+// updateFrontmatter sets and removes top-level keys in the note's frontmatter
+// while leaving the body untouched. Other keys keep their order and comments.
+func updateFrontmatter(content string, set map[string]any, remove []string) (string, error) {
+	block, body, ok := splitFrontmatter(content)
+	if !ok {
+		body = content
+	}
+
+	var doc yaml.Node
+	if ok && strings.TrimSpace(block) != "" {
+		if err := yaml.Unmarshal([]byte(block), &doc); err != nil {
+			return "", fmt.Errorf("invalid frontmatter: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return "", errors.New("frontmatter must be a mapping")
+	}
+
+	for _, key := range remove {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == key {
+				mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+				break
+			}
+		}
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := set[key]
+		if strings.TrimSpace(key) == "" {
+			return "", errors.New("frontmatter keys must not be empty")
+		}
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
+			return "", fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		replaced := false
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == key {
+				// Keep the old value's style, such as [a, b] lists, and comments.
+				if previous := mapping.Content[i+1]; previous.Kind == valueNode.Kind {
+					valueNode.Style = previous.Style
+					valueNode.LineComment = previous.LineComment
+				}
+				mapping.Content[i+1] = &valueNode
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &valueNode)
+		}
+	}
+
+	if len(mapping.Content) == 0 {
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return frontmatterDelimiter + "\n" + buf.String() + frontmatterDelimiter + "\n" + body, nil
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxGraphDepth = 5
+
+type GraphNode struct {
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	Label  string   `json:"label"`
+	Path   string   `json:"path,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+	Weight int    `json:"weight"`
+}
+
+type GraphResponse struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	folder := ""
+	if raw := strings.TrimSpace(query.Get("folder")); raw != "" {
+		_, relFolder, err := s.resolvePath(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		folder = relFolder
+	}
+	tagFilter := strings.TrimPrefix(strings.TrimSpace(query.Get("tag")), "#")
+	center := strings.TrimSpace(query.Get("note"))
+	if center != "" {
+		_, relCenter, err := s.resolvePath(center)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		center = relCenter
+	}
+	depth := 1
+	if raw := strings.TrimSpace(query.Get("depth")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 || parsed > maxGraphDepth {
+			writeError(w, http.StatusBadRequest, "depth must be between 0 and 5")
+			return
+		}
+		depth = parsed
+	}
+
+	index, err := s.buildLinkIndex()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to index notes")
+		return
+	}
+	if center != "" {
+		if _, ok := index.byPath[linkKey(center)]; !ok {
+			writeError(w, http.StatusNotFound, "note not found")
+			return
+		}
+	}
+
+	noteTags := make(map[string][]string)
+	links := make(map[[2]string]int)
+	err = s.walkNotes(func(rel string, data []byte) error {
+		content := string(data)
+		noteTags[rel] = extractNoteTags(content)
+		for _, link := range extractNoteLinks(content) {
+			target := index.resolveLink(rel, link)
+			if target == "" || target == rel {
+				continue
+			}
+			links[[2]string{rel, target}]++
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to build graph")
+		return
+	}
+
+	included := make(map[string]bool, len(index.paths))
+	for _, relPath := range index.paths {
+		included[relPath] = true
+	}
+	if center != "" {
+		included = graphNeighborhood(index.byPath[linkKey(center)], depth, links)
+	}
+	for relPath := range included {
+		if folder != "" && !strings.HasPrefix(relPath, folder+"/") {
+			delete(included, relPath)
+			continue
+		}
+		if tagFilter != "" && !tagsContainFold(noteTags[relPath], tagFilter) {
+			delete(included, relPath)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, buildGraph(included, noteTags, links))
+}
+
+// graphNeighborhood returns the notes within depth link hops of start,
+// following links in either direction.
+func graphNeighborhood(start string, depth int, links map[[2]string]int) map[string]bool {
+	neighbors := make(map[string][]string)
+	for pair := range links {
+		neighbors[pair[0]] = append(neighbors[pair[0]], pair[1])
+		neighbors[pair[1]] = append(neighbors[pair[1]], pair[0])
+	}
+
+	seen := map[string]bool{start: true}
+	frontier := []string{start}
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		var next []string
+		for _, node := range frontier {
+			for _, neighbor := range neighbors[node] {
+				if seen[neighbor] {
+					continue
+				}
+				seen[neighbor] = true
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+func buildGraph(included map[string]bool, noteTags map[string][]string, links map[[2]string]int) GraphResponse {
+	paths := make([]string, 0, len(included))
+	for relPath := range included {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+
+	resp := GraphResponse{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	// Tag nodes are keyed by tagKey so #Work and #work are one node.
+	tagNodes := make(map[string]map[string]int)
+	for _, relPath := range paths {
+		folder := path.Dir(relPath)
+		if folder == "." {
+			folder = ""
+		}
+		tags := noteTags[relPath]
+		resp.Nodes = append(resp.Nodes, GraphNode{
+			ID:     relPath,
+			Type:   "note",
+			Label:  noteTitle(relPath),
+			Path:   relPath,
+			Folder: folder,
+			Tags:   tags,
+		})
+		linked := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			key := tagKey(tag)
+			if tagNodes[key] == nil {
+				tagNodes[key] = make(map[string]int)
+			}
+			tagNodes[key][tag]++
+			if linked[key] {
+				continue
+			}
+			linked[key] = true
+			resp.Edges = append(resp.Edges, GraphEdge{Source: relPath, Target: "tag:" + key, Type: "tag", Weight: 1})
+		}
+	}
+
+	keys := make([]string, 0, len(tagNodes))
+	for key := range tagNodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		resp.Nodes = append(resp.Nodes, GraphNode{ID: "tag:" + key, Type: "tag", Label: "#" + tagSpelling(tagNodes[key])})
+	}
+
+	linkEdges := make([]GraphEdge, 0, len(links))
+	for pair, count := range links {
+		if !included[pair[0]] || !included[pair[1]] {
+			continue
+		}
+		linkEdges = append(linkEdges, GraphEdge{Source: pair[0], Target: pair[1], Type: "link", Weight: count})
+	}
+	sort.Slice(linkEdges, func(i, j int) bool {
+		if linkEdges[i].Source == linkEdges[j].Source {
+			return linkEdges[i].Target < linkEdges[j].Target
+		}
+		return linkEdges[i].Source < linkEdges[j].Source
+	})
+	resp.Edges = append(linkEdges, resp.Edges...)
+	return resp
+}
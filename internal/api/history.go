@@ -0,0 +1,370 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	historyDirName        = ".history"
+	historyIDLayout       = "20060102T150405.000000000"
+	defaultHistoryMaxRevs = 50
+)
+
+type Revision struct {
+	ID      string    `json:"id"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+type RevisionResponse struct {
+	Path     string    `json:"path"`
+	ID       string    `json:"id"`
+	Reason   string    `json:"reason"`
+	Created  time.Time `json:"created"`
+	Content  string    `json:"content"`
+	Hash     string    `json:"hash"`
+	Modified time.Time `json:"modified"`
+}
+
+type RevisionDiffResponse struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Diff string `json:"diff"`
+}
+
+type NoteRestorePayload struct {
+	Path     string `json:"path"`
+	ID       string `json:"id"`
+	BaseHash string `json:"baseHash,omitempty"`
+}
+
+func (s *Server) handleNoteHistory(w http.ResponseWriter, r *http.Request) {
+	_, relPath, ok := s.resolveNoteParam(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+
+	revisions, err := s.listRevisions(relPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list history")
+		return
+	}
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+func (s *Server) handleNoteRevision(w http.ResponseWriter, r *http.Request) {
+	_, relPath, ok := s.resolveNoteParam(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if !isSafeID(id) {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	data, info, err := s.readRevision(relPath, id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "revision not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read revision")
+		return
+	}
+
+	rev := revisionFromName(info.Name(), info.Size())
+	writeJSON(w, http.StatusOK, RevisionResponse{
+		Path:     relPath,
+		ID:       rev.ID,
+		Reason:   rev.Reason,
+		Created:  rev.Created,
+		Content:  string(data),
+		Hash:     noteHash(data),
+		Modified: info.ModTime(),
+	})
+}
+
+func (s *Server) handleNoteDiff(w http.ResponseWriter, r *http.Request) {
+	absPath, relPath, ok := s.resolveNoteParam(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+	from := strings.TrimSpace(r.URL.Query().Get("from"))
+	to := strings.TrimSpace(r.URL.Query().Get("to"))
+	if to == "" {
+		to = "current"
+	}
+	if from == "" {
+		writeError(w, http.StatusBadRequest, "from is required")
+		return
+	}
+
+	load := func(id string) ([]byte, bool) {
+		if id == "current" {
+			data, err := os.ReadFile(absPath)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "unable to read note")
+				return nil, false
+			}
+			return data, true
+		}
+		if !isSafeID(id) {
+			writeError(w, http.StatusBadRequest, "invalid revision id")
+			return nil, false
+		}
+		data, _, err := s.readRevision(relPath, id)
+		if err != nil {
+			if os.IsNotExist(err) {
+				writeError(w, http.StatusNotFound, "revision not found")
+				return nil, false
+			}
+			writeError(w, http.StatusInternalServerError, "unable to read revision")
+			return nil, false
+		}
+		return data, true
+	}
+
+	fromData, ok := load(from)
+	if !ok {
+		return
+	}
+	toData, ok := load(to)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RevisionDiffResponse{
+		Path: relPath,
+		From: from,
+		To:   to,
+		Diff: unifiedDiff(relPath+"@"+from, relPath+"@"+to, string(fromData), string(toData)),
+	})
+}
+
+func (s *Server) handleNoteRestore(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[NoteRestorePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	absPath, relPath, ok := s.resolveNoteParam(w, payload.Path)
+	if !ok {
+		return
+	}
+	if !isSafeID(payload.ID) {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	data, _, err := s.readRevision(relPath, payload.ID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "revision not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read revision")
+		return
+	}
+
+	defer s.lockNote(relPath)()
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return
+	}
+	expected := expectedNoteHash(r.Header.Get("If-Match"), payload.BaseHash)
+	if expected != "" && expected != "*" && expected != noteHash(current) {
+		writeError(w, http.StatusConflict, "note changed on the server")
+		return
+	}
+
+	s.snapshotNote(relPath, current, "restore")
+	if err := writeFileAtomic(absPath, data, 0o644); err != nil {
+		s.logger.Error("unable to restore note", "path", relPath, "id", payload.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to restore note")
+		return
+	}
+	s.indexPath(relPath)
+
+	hash := noteHash(data)
+	s.logger.Info("note restored", "path", relPath, "id", payload.ID)
+	w.Header().Set("ETag", formatETag(hash))
+	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "id": payload.ID, "hash": hash})
+}
+
+func (s *Server) resolveNoteParam(w http.ResponseWriter, pathParam string) (string, string, bool) {
+	if strings.TrimSpace(pathParam) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return "", "", false
+	}
+	absPath, relPath, err := s.resolvePath(pathParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "note not found")
+			return "", "", false
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return "", "", false
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "path is a folder")
+		return "", "", false
+	}
+	if !isNoteFile(absPath) {
+		writeError(w, http.StatusBadRequest, "not a note file")
+		return "", "", false
+	}
+	return absPath, relPath, true
+}
+
+func (s *Server) historyDir(relPath string) string {
+	return filepath.Join(s.notesDir, historyDirName, filepath.FromSlash(relPath))
+}
+
+// snapshotNote stores content as a revision of relPath and prunes revisions
+// beyond the configured retention. History is best effort: failures are
+// logged and never block the write that triggered the snapshot.
+func (s *Server) snapshotNote(relPath string, content []byte, reason string) {
+	dir := s.historyDir(relPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		s.logger.Warn("unable to create history folder", "path", relPath, "error", err)
+		return
+	}
+	name := timeNow().UTC().Format(historyIDLayout) + "_" + reason + ".md"
+	if err := writeFileAtomic(filepath.Join(dir, name), content, 0o644); err != nil {
+		s.logger.Warn("unable to write revision", "path", relPath, "error", err)
+		return
+	}
+	if err := s.pruneRevisions(relPath); err != nil {
+		s.logger.Warn("unable to prune history", "path", relPath, "error", err)
+	}
+}
+
+func (s *Server) pruneRevisions(relPath string) error {
+	settings, _, err := s.loadSettings()
+	if err != nil {
+		return err
+	}
+	revisions, err := s.listRevisions(relPath)
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if settings.HistoryMaxAgeDays > 0 {
+		cutoff = timeNow().AddDate(0, 0, -settings.HistoryMaxAgeDays)
+	}
+	for i, rev := range revisions {
+		expired := !cutoff.IsZero() && rev.Created.Before(cutoff)
+		if i < settings.HistoryMaxRevisions && !expired {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.historyDir(relPath), rev.ID+".md")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveHistory keeps revisions attached to a note or folder after a rename.
+func (s *Server) moveHistory(relPath, relNewPath string) {
+	s.moveHistoryDir(s.historyDir(relPath), s.historyDir(relNewPath))
+}
+
+// moveHistoryDir moves the revisions in oldDir to newDir, if there are any.
+// Like snapshots, moving history is best effort and only logs failures.
+func (s *Server) moveHistoryDir(oldDir, newDir string) {
+	if _, err := os.Stat(oldDir); err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(newDir), 0o755); err != nil {
+		s.logger.Warn("unable to move history", "from", oldDir, "to", newDir, "error", err)
+		return
+	}
+	if err := os.Rename(oldDir, newDir); err != nil {
+		s.logger.Warn("unable to move history", "from", oldDir, "to", newDir, "error", err)
+	}
+}
+
+func (s *Server) listRevisions(relPath string) ([]Revision, error) {
+	entries, err := os.ReadDir(s.historyDir(relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Revision{}, nil
+		}
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || isIgnoredFile(entry.Name()) || !isMarkdown(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		rev := revisionFromName(entry.Name(), info.Size())
+		if rev.Created.IsZero() {
+			continue
+		}
+		revisions = append(revisions, rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ID > revisions[j].ID
+	})
+	return revisions, nil
+}
+
+func (s *Server) readRevision(relPath, id string) ([]byte, os.FileInfo, error) {
+	path := filepath.Join(s.historyDir(relPath), id+".md")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, info, nil
+}
+
+// findRevision returns the content of the newest revision of relPath whose
+// hash is hash, or nil when the history has none.
+func (s *Server) findRevision(relPath, hash string) *string {
+	revisions, err := s.listRevisions(relPath)
+	if err != nil {
+		return nil
+	}
+	for _, rev := range revisions {
+		data, _, err := s.readRevision(relPath, rev.ID)
+		if err == nil && noteHash(data) == hash {
+			content := string(data)
+			return &content
+		}
+	}
+	return nil
+}
+
+func revisionFromName(name string, size int64) Revision {
+	id := strings.TrimSuffix(name, filepath.Ext(name))
+	stamp, reason, _ := strings.Cut(id, "_")
+	created, err := time.ParseInLocation(historyIDLayout, stamp, time.UTC)
+	if err != nil {
+		return Revision{ID: id}
+	}
+	return Revision{ID: id, Reason: reason, Created: created, Size: size}
+}
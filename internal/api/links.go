@@ -0,0 +1,325 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	wikiLinkPattern     = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+	markdownLinkPattern = regexp.MustCompile(`(!?)\[([^\]]*)\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+)
+
+// noteLink is a single link found in a note. Start and End are byte offsets
+// of the whole link within its line; TargetStart and TargetEnd cover just the
+// wiki link contents or the markdown href.
+type noteLink struct {
+	Kind        string
+	Target      string
+	Image       bool
+	LineNumber  int
+	Line        string
+	Start       int
+	End         int
+	TargetStart int
+	TargetEnd   int
+}
+
+type WikiLink struct {
+	Target  string `json:"target"`
+	Heading string `json:"heading,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+}
+
+type LinkResolution struct {
+	Target     string   `json:"target"`
+	Status     string   `json:"status"`
+	Path       string   `json:"path,omitempty"`
+	Heading    string   `json:"heading,omitempty"`
+	Alias      string   `json:"alias,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// linkIndex maps note names, frontmatter aliases, and paths to note files so
+// wiki link targets can be resolved.
+type linkIndex struct {
+	paths   []string
+	byPath  map[string]string
+	byName  map[string][]string
+	byAlias map[string][]string
+}
+
+func (s *Server) handleLinksResolve(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimSpace(r.URL.Query().Get("target"))
+	if target == "" {
+		writeError(w, http.StatusBadRequest, "target is required")
+		return
+	}
+	from := strings.TrimSpace(r.URL.Query().Get("from"))
+	if from != "" {
+		_, relFrom, err := s.resolvePath(from)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		from = relFrom
+	}
+
+	index, err := s.buildLinkIndex()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to index notes")
+		return
+	}
+
+	if match := wikiLinkPattern.FindStringSubmatch(target); match != nil {
+		target = match[1]
+	}
+	link := parseWikiLink(target)
+	resolution := LinkResolution{
+		Target:  link.Target,
+		Heading: link.Heading,
+		Alias:   link.Alias,
+	}
+	var candidates []string
+	if link.Target == "" && from != "" {
+		// [[#Heading]] points into the linking note itself.
+		candidates = []string{from}
+	} else {
+		candidates = index.resolve(link.Target, from)
+	}
+	switch len(candidates) {
+	case 0:
+		resolution.Status = "missing"
+	case 1:
+		resolution.Status = "resolved"
+		resolution.Path = candidates[0]
+	default:
+		resolution.Status = "ambiguous"
+		resolution.Candidates = candidates
+	}
+
+	writeJSON(w, http.StatusOK, resolution)
+}
+
+func (s *Server) buildLinkIndex() (*linkIndex, error) {
+	index := &linkIndex{
+		byPath:  make(map[string]string),
+		byName:  make(map[string][]string),
+		byAlias: make(map[string][]string),
+	}
+
+	err := s.walkNotes(func(rel string, data []byte) error {
+		frontmatter, _ := parseFrontmatter(string(data))
+		index.add(rel, frontmatterList(frontmatter, "aliases"))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(index.paths)
+	for key := range index.byName {
+		sort.Strings(index.byName[key])
+	}
+	for key := range index.byAlias {
+		sort.Strings(index.byAlias[key])
+	}
+	return index, nil
+}
+
+func (idx *linkIndex) add(relPath string, aliases []string) {
+	idx.paths = append(idx.paths, relPath)
+	idx.byPath[linkKey(relPath)] = relPath
+	name := linkKey(path.Base(relPath))
+	idx.byName[name] = append(idx.byName[name], relPath)
+	for _, alias := range aliases {
+		key := strings.ToLower(strings.TrimSpace(alias))
+		idx.byAlias[key] = append(idx.byAlias[key], relPath)
+	}
+}
+
+// resolve returns the notes a wiki link target could refer to. Targets with
+// a folder match the full path first and then any path ending in the target;
+// bare names match note file names, then frontmatter aliases. When several
+// notes share a name, a note in the same folder as from wins.
+func (idx *linkIndex) resolve(target, from string) []string {
+	key := linkKey(target)
+	if key == "" {
+		return nil
+	}
+
+	if strings.Contains(key, "/") {
+		if relPath, ok := idx.byPath[key]; ok {
+			return []string{relPath}
+		}
+		var matches []string
+		for _, relPath := range idx.paths {
+			if strings.HasSuffix(linkKey(relPath), "/"+key) {
+				matches = append(matches, relPath)
+			}
+		}
+		return matches
+	}
+
+	matches := idx.byName[key]
+	if len(matches) == 0 {
+		matches = idx.byAlias[strings.ToLower(strings.TrimSpace(target))]
+	}
+	if len(matches) > 1 && from != "" {
+		fromDir := path.Dir(from)
+		for _, relPath := range matches {
+			if path.Dir(relPath) == fromDir {
+				return []string{relPath}
+			}
+		}
+	}
+	return append([]string(nil), matches...)
+}
+
+// linkKey normalizes a note path or link target for case-insensitive
+// comparison, dropping any leading slash and the .md extension.
+func linkKey(value string) string {
+	key := strings.TrimSpace(filepath.ToSlash(value))
+	key = strings.TrimPrefix(key, "/")
+	key = strings.TrimPrefix(key, "./")
+	if isMarkdown(key) {
+		key = key[:len(key)-len(".md")]
+	}
+	return strings.ToLower(key)
+}
+
+// parseWikiLink splits the inside of [[...]] into target, heading, and alias,
+// e.g. "Folder/Note#Heading|Alias".
+func parseWikiLink(inner string) WikiLink {
+	var link WikiLink
+	target, alias, hasAlias := strings.Cut(inner, "|")
+	if hasAlias {
+		link.Alias = strings.TrimSpace(alias)
+	}
+	target, heading, hasHeading := strings.Cut(target, "#")
+	if hasHeading {
+		link.Heading = strings.TrimSpace(heading)
+	}
+	link.Target = strings.TrimSpace(target)
+	return link
+}
+
+// extractNoteLinks returns every wiki link and markdown link or image in
+// content, skipping fenced code blocks and inline code as findTags does.
+func extractNoteLinks(content string) []noteLink {
+	var links []noteLink
+	var fence codeFence
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if fence.skip(line) {
+			continue
+		}
+		spans := inlineCodeSpans(line)
+		for _, loc := range wikiLinkPattern.FindAllStringSubmatchIndex(line, -1) {
+			if inSpans(spans, loc[0]) {
+				continue
+			}
+			links = append(links, noteLink{
+				Kind:        "wiki",
+				Target:      line[loc[2]:loc[3]],
+				LineNumber:  i + 1,
+				Line:        line,
+				Start:       loc[0],
+				End:         loc[1],
+				TargetStart: loc[2],
+				TargetEnd:   loc[3],
+			})
+		}
+		for _, loc := range markdownLinkPattern.FindAllStringSubmatchIndex(line, -1) {
+			if inSpans(spans, loc[0]) {
+				continue
+			}
+			links = append(links, noteLink{
+				Kind:        "markdown",
+				Target:      line[loc[6]:loc[7]],
+				Image:       loc[3] > loc[2],
+				LineNumber:  i + 1,
+				Line:        line,
+				Start:       loc[0],
+				End:         loc[1],
+				TargetStart: loc[6],
+				TargetEnd:   loc[7],
+			})
+		}
+	}
+	return links
+}
+
+// resolveMarkdownHref turns a markdown link href found in the note at fromRel
+// into a notes-relative path. External URLs, in-page anchors, and hrefs that
+// escape the notes directory are rejected. The /files?path= form used for
+// images is resolved against the notes root.
+func resolveMarkdownHref(fromRel, href string) (string, bool) {
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	if strings.HasPrefix(href, "/files?") {
+		parsed, err := url.Parse(href)
+		if err != nil {
+			return "", false
+		}
+		cleaned, err := cleanRelPath(parsed.Query().Get("path"))
+		if err != nil || cleaned == "" {
+			return "", false
+		}
+		return filepath.ToSlash(cleaned), true
+	}
+	if parsed, err := url.Parse(href); err != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return "", false
+	}
+	href, _, _ = strings.Cut(href, "#")
+	href, _, _ = strings.Cut(href, "?")
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+
+	var joined string
+	if strings.HasPrefix(href, "/") {
+		joined = strings.TrimPrefix(href, "/")
+	} else {
+		joined = path.Join(path.Dir(fromRel), href)
+	}
+	cleaned, err := cleanRelPath(joined)
+	if err != nil || cleaned == "" {
+		return "", false
+	}
+	return filepath.ToSlash(cleaned), true
+}
+
+// resolveLink returns the note path a link points at, or "" when it does not
+// point at exactly one existing note.
+func (idx *linkIndex) resolveLink(fromRel string, link noteLink) string {
+	switch link.Kind {
+	case "wiki":
+		wiki := parseWikiLink(link.Target)
+		if wiki.Target == "" {
+			return ""
+		}
+		candidates := idx.resolve(wiki.Target, fromRel)
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+	case "markdown":
+		if link.Image {
+			return ""
+		}
+		target, ok := resolveMarkdownHref(fromRel, link.Target)
+		if !ok || !isMarkdown(target) {
+			return ""
+		}
+		if resolved, ok := idx.byPath[linkKey(target)]; ok && resolved == target {
+			return target
+		}
+	}
+	return ""
+}
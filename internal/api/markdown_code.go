@@ -0,0 +1,73 @@
+package api
+
+import "strings"
+
+// codeFence tracks fenced code blocks while a note is read line by line. A
+// block opens with a run of three or more ` or ~ and closes at a line starting
+// with at least as long a run of the same character. Links and tags are not
+// read inside code.
+type codeFence struct {
+	marker string
+}
+
+// skip reports whether line opens, closes, or is inside a fenced code block.
+func (f *codeFence) skip(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if f.marker != "" {
+		if strings.HasPrefix(trimmed, f.marker) {
+			f.marker = ""
+		}
+		return true
+	}
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return false
+	}
+	run := 0
+	for run < len(trimmed) && trimmed[run] == trimmed[0] {
+		run++
+	}
+	f.marker = trimmed[:run]
+	return true
+}
+
+// inlineCodeSpans returns the byte ranges of the inline code spans in line: a
+// run of backticks up to the next run of the same length.
+func inlineCodeSpans(line string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		open := i
+		for i < len(line) && line[i] == '`' {
+			i++
+		}
+		// An unmatched run is literal text and the scan moves past it.
+		for j := i; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			run := j
+			for j < len(line) && line[j] == '`' {
+				j++
+			}
+			if j-run == i-open {
+				spans = append(spans, [2]int{open, j})
+				i = j
+				break
+			}
+		}
+	}
+	return spans
+}
+
+func inSpans(spans [][2]int, offset int) bool {
+	for _, span := range spans {
+		if offset >= span[0] && offset < span[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -13,11 +13,10 @@ var (
 	todoTogglePattern    = regexp.MustCompile(`^(\s*-\s+\[)( |x|X|✓)(\]\s+)`)
 	todoCompletedPattern = regexp.MustCompile(`^\s*-\s+\[(x|X|✓)\]\s+`)
 	taskProjectPattern   = regexp.MustCompile(`(^|\s)\+([A-Za-z]+)\b`)
-	taskTagPattern       = regexp.MustCompile(`(^|\s)#([A-Za-z]+)\b`)
 	taskMentionPattern   = regexp.MustCompile(`(^|\s)@([A-Za-z]+)\b`)
 	taskDuePattern       = regexp.MustCompile(`(^|\s)>(\S+)`)
 	taskPriorityPattern  = regexp.MustCompile(`(^|\s)\^([1-5])\b`)
-	taskTokenPattern     = regexp.MustCompile(`(^|\s)(#[A-Za-z]+|@[A-Za-z]+|\+[A-Za-z]+|\^[1-5]|>\S+)`)
+	taskTokenPattern     = regexp.MustCompile(`(^|\s)(#` + tagBody + `|@[A-Za-z]+|\+[A-Za-z]+|\^[1-5]|>\S+|\*every:\S+)`)
 )
 
 type ParsedTodo struct {
@@ -32,13 +31,28 @@ type ParsedTodo struct {
 	DueDateISO   string
 	DueDateValid bool
 	Priority     int
+	Recurrence   string
+	Indent       int
+	ParentLine   int
 }
 
-func parseTodoLines(content string) []ParsedTodo {
+// parseTodoLines parses the tasks in content. Relative due dates are resolved
+// against base; see dueDateBase. A task indented below another task is its
+// subtask (ParentLine); blank lines keep the nesting, while any other line
+// ends the nesting of the tasks indented at least as far.
+func parseTodoLines(content string, base time.Time) []ParsedTodo {
 	lines := strings.Split(content, "\n")
 	todos := make([]ParsedTodo, 0)
+	var open []ParsedTodo
 	for i, line := range lines {
 		raw := strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		indent := indentWidth(raw)
+		for len(open) > 0 && open[len(open)-1].Indent >= indent {
+			open = open[:len(open)-1]
+		}
 		loc := todoLinePattern.FindStringIndex(raw)
 		if loc == nil {
 			continue
@@ -54,18 +68,26 @@ func parseTodoLines(content string) []ParsedTodo {
 
 		completed := match[1] != " "
 		project := extractFirstMatch(taskProjectPattern, rest)
-		tags := extractMatches(taskTagPattern, rest)
+		tags := extractMatches(tagPattern, rest)
 		mentions := extractMatches(taskMentionPattern, rest)
 		priority := extractPriority(rest)
 		dueRaw := extractDueDate(rest)
-		dueISO, dueValid := normalizeDueDate(dueRaw)
+		dueISO, dueValid := normalizeDueDate(dueRaw, base)
+		recurrence := extractFirstMatch(taskRecurrencePattern, rest)
+		if _, ok := parseRecurrence(recurrence); !ok {
+			recurrence = ""
+		}
 
 		text := cleanTaskText(rest)
 		if text == "" {
 			text = strings.TrimSpace(rest)
 		}
 
-		todos = append(todos, ParsedTodo{
+		parentLine := 0
+		if len(open) > 0 {
+			parentLine = open[len(open)-1].LineNumber
+		}
+		todo := ParsedTodo{
 			LineNumber:   i + 1,
 			LineHash:     hashLine(raw),
 			Text:         text,
@@ -77,11 +99,47 @@ func parseTodoLines(content string) []ParsedTodo {
 			DueDateISO:   dueISO,
 			DueDateValid: dueValid,
 			Priority:     priority,
-		})
+			Recurrence:   strings.ToLower(recurrence),
+			Indent:       indent,
+			ParentLine:   parentLine,
+		}
+		todos = append(todos, todo)
+		open = append(open, todo)
 	}
 	return todos
 }
 
+// indentWidth measures the leading whitespace of line in columns, with tabs
+// stopping at multiples of four.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4 - width%4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// taskSubtree returns the line numbers of the tasks nested below the task on
+// lineNumber, in note order.
+func taskSubtree(todos []ParsedTodo, lineNumber int) []int {
+	inside := map[int]bool{lineNumber: true}
+	var nested []int
+	for _, todo := range todos {
+		if todo.LineNumber > lineNumber && inside[todo.ParentLine] {
+			inside[todo.LineNumber] = true
+			nested = append(nested, todo.LineNumber)
+		}
+	}
+	return nested
+}
+
 func setTaskLineCompletion(line string, completed bool) (string, bool) {
 	match := todoTogglePattern.FindStringSubmatchIndex(line)
 	if match == nil || len(match) < 6 {
@@ -180,7 +238,9 @@ func extractDueDate(text string) string {
 	return strings.TrimRight(match[2], ".,;:)]}")
 }
 
-func normalizeDueDate(raw string) (string, bool) {
+// normalizeDueDate returns raw as a YYYY-MM-DD date. Absolute layouts are
+// tried first, then the relative forms of resolveRelativeDate.
+func normalizeDueDate(raw string, base time.Time) (string, bool) {
 	if raw == "" {
 		return "", false
 	}
@@ -203,6 +263,9 @@ func normalizeDueDate(raw string) (string, bool) {
 			return parsed.Format("2006-01-02"), true
 		}
 	}
+	if resolved, ok := resolveRelativeDate(raw, base); ok {
+		return resolved.Format("2006-01-02"), true
+	}
 	return "", false
 }
 
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	defaultQuickLimit = 20
+	maxQuickLimit     = 100
+	maxRecentNotes    = 100
+
+	fuzzyMatchScore       = 16
+	fuzzySegmentBonus     = 10
+	fuzzyWordStartBonus   = 8
+	fuzzyConsecutiveBonus = 6
+	fuzzyBaseNameBonus    = 4
+	fuzzyRecentBonus      = 24
+)
+
+type QuickResult struct {
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions,omitempty"`
+}
+
+// recentNotes remembers which notes were opened most recently, newest first,
+// so the quick switcher can rank them higher.
+type recentNotes struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *recentNotes) touch(relPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.paths {
+		if existing == relPath {
+			r.paths = append(r.paths[:i], r.paths[i+1:]...)
+			break
+		}
+	}
+	r.paths = append([]string{relPath}, r.paths...)
+	if len(r.paths) > maxRecentNotes {
+		r.paths = r.paths[:maxRecentNotes]
+	}
+}
+
+// ranks returns each recent path's position, 0 being the most recent.
+func (r *recentNotes) ranks() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ranks := make(map[string]int, len(r.paths))
+	for i, relPath := range r.paths {
+		ranks[relPath] = i
+	}
+	return ranks
+}
+
+func (s *Server) handleQuickSwitch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	limit := defaultQuickLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxQuickLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	candidates, err := s.listQuickCandidates()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list notes")
+		return
+	}
+	recent := s.recent.ranks()
+	pattern := lowerRunes(strings.Join(strings.Fields(query), ""))
+
+	results := make([]QuickResult, 0, limit)
+	for _, candidate := range candidates {
+		score := 0
+		if len(pattern) > 0 {
+			var ok bool
+			score, _, ok = fuzzyMatch(pattern, candidate.Path, false)
+			if !ok {
+				continue
+			}
+		} else if _, ok := recent[candidate.Path]; !ok {
+			// Without a query the switcher lists recently opened notes.
+			continue
+		}
+		if rank, ok := recent[candidate.Path]; ok && rank < fuzzyRecentBonus {
+			score += fuzzyRecentBonus - rank
+		}
+		candidate.Score = score
+		results = append(results, candidate)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].Path) != len(results[j].Path) {
+			return len(results[i].Path) < len(results[j].Path)
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if len(pattern) > 0 {
+		for i := range results {
+			_, results[i].Positions, _ = fuzzyMatch(pattern, results[i].Path, true)
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// listQuickCandidates lists note and folder paths without reading any files.
+func (s *Server) listQuickCandidates() ([]QuickResult, error) {
+	var candidates []QuickResult
+	err := filepath.WalkDir(s.notesDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == s.notesDir {
+			return nil
+		}
+		if d.IsDir() && s.isReservedDir(p) {
+			return filepath.SkipDir
+		}
+		if isIgnoredFile(d.Name()) || (!d.IsDir() && !isMarkdown(d.Name())) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.notesDir, p)
+		if err != nil {
+			return err
+		}
+		candidate := QuickResult{Path: filepath.ToSlash(rel), Name: d.Name(), Type: "note"}
+		if d.IsDir() {
+			candidate.Type = "folder"
+		}
+		candidates = append(candidates, candidate)
+		return nil
+	})
+	return candidates, err
+}
+
+// fuzzyMatch scores pattern (lowercase) as a subsequence of target. Each
+// matched character earns a base score, plus bonuses when it starts a path
+// segment or word, follows the previous match directly, or falls in the last
+// path segment; skipped characters between matches cost one point each. It
+// picks the best scoring alignment and, when trace is set, returns the
+// matched character indexes.
+func fuzzyMatch(pattern []rune, target string, trace bool) (int, []int, bool) {
+	text := []rune(target)
+	lower := lowerRunes(target)
+	if !isSubsequence(pattern, lower) {
+		return 0, nil, false
+	}
+
+	baseStart := strings.LastIndex(target, "/") + 1
+	baseStart = len([]rune(target[:baseStart]))
+	bonus := make([]int, len(text))
+	for j, r := range text {
+		bonus[j] = fuzzyMatchScore
+		switch {
+		case j == 0 || text[j-1] == '/':
+			bonus[j] += fuzzySegmentBonus
+		case isWordBoundary(text[j-1], r):
+			bonus[j] += fuzzyWordStartBonus
+		}
+		if j >= baseStart {
+			bonus[j] += fuzzyBaseNameBonus
+		}
+	}
+
+	const none = -1 << 30
+	n, m := len(pattern), len(text)
+	prev := make([]int, m)
+	cur := make([]int, m)
+	var from [][]int
+	if trace {
+		from = make([][]int, n)
+	}
+	for i := 0; i < n; i++ {
+		if trace {
+			from[i] = make([]int, m)
+		}
+		gapBest, gapFrom := none, -1
+		for j := 0; j < m; j++ {
+			cur[j] = none
+			if i > 0 && j >= 2 && prev[j-2] != none && prev[j-2]-1 > gapBest-1 {
+				gapBest, gapFrom = prev[j-2]-1, j-2
+			} else if gapBest != none {
+				gapBest--
+			}
+			if lower[j] != pattern[i] {
+				continue
+			}
+			if i == 0 {
+				cur[j] = bonus[j] - j/4
+				continue
+			}
+			best, bestFrom := gapBest, gapFrom
+			if j >= 1 && prev[j-1] != none && prev[j-1]+fuzzyConsecutiveBonus >= best {
+				best, bestFrom = prev[j-1]+fuzzyConsecutiveBonus, j-1
+			}
+			if best == none {
+				continue
+			}
+			cur[j] = best + bonus[j]
+			if trace {
+				from[i][j] = bestFrom
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	score, end := none, -1
+	for j, value := range prev {
+		if value > score {
+			score, end = value, j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	if !trace {
+		return score, nil, true
+	}
+	positions := make([]int, n)
+	for i := n - 1; i >= 0; i-- {
+		positions[i] = end
+		if i > 0 {
+			end = from[i][end]
+		}
+	}
+	return score, positions, true
+}
+
+// lowerRunes lowercases text rune by rune so indexes line up with the
+// original.
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func isSubsequence(pattern, text []rune) bool {
+	i := 0
+	for _, r := range text {
+		if i < len(pattern) && r == pattern[i] {
+			i++
+		}
+	}
+	return i == len(pattern)
+}
+
+func isWordBoundary(prev, r rune) bool {
+	if unicode.IsLower(prev) && unicode.IsUpper(r) {
+		return true
+	}
+	if unicode.IsDigit(r) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
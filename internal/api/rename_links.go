@@ -0,0 +1,235 @@
+package api
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type RenameResponse struct {
+	Path    string   `json:"path"`
+	NewPath string   `json:"newPath"`
+	Updated []string `json:"updated,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// linkRewrite is the new content for a note whose links change because of a
+// rename. Path is where the note lives after the rename; Hash is the note's
+// hash when the rewrite was planned.
+type linkRewrite struct {
+	Path    string
+	Content string
+	Hash    string
+}
+
+// movedFiles lists every file under a folder so links to any of them can be
+// rewritten when the folder is renamed.
+func (s *Server) movedFiles(absPath, relPath, relNewPath string) (map[string]string, error) {
+	moved := make(map[string]string)
+	err := filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isIgnoredFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(absPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		moved[path.Join(relPath, rel)] = path.Join(relNewPath, rel)
+		return nil
+	})
+	return moved, err
+}
+
+// planLinkRewrites computes the notes that need new content once the files in
+// moved (old path -> new path) are renamed. It must run before the rename so
+// wiki links resolve against the current layout. Links inside moved notes
+// that point outside the move are updated too, since their relative hrefs
+// change with the note's location.
+func (s *Server) planLinkRewrites(moved map[string]string) ([]linkRewrite, error) {
+	index, err := s.buildLinkIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	nameCounts := make(map[string]int)
+	for _, relPath := range index.paths {
+		if newPath, ok := moved[relPath]; ok {
+			relPath = newPath
+		}
+		nameCounts[linkKey(path.Base(relPath))]++
+	}
+
+	exists := func(relPath string) bool {
+		_, err := os.Stat(filepath.Join(s.notesDir, filepath.FromSlash(relPath)))
+		return err == nil
+	}
+
+	var rewrites []linkRewrite
+	err = s.walkNotes(func(rel string, data []byte) error {
+		newSrc := rel
+		if target, ok := moved[rel]; ok {
+			newSrc = target
+		}
+
+		content := string(data)
+		lines := strings.Split(content, "\n")
+		links := extractNoteLinks(content)
+		changed := false
+		// Replace from the end so earlier offsets on the same line stay valid.
+		for i := len(links) - 1; i >= 0; i-- {
+			link := links[i]
+			var replacement string
+			var ok bool
+			switch link.Kind {
+			case "wiki":
+				replacement, ok = rewriteWikiTarget(link.Target, rel, index, moved, nameCounts)
+			case "markdown":
+				replacement, ok = rewriteMarkdownHref(link.Target, rel, newSrc, moved, exists)
+			}
+			if !ok || replacement == link.Target {
+				continue
+			}
+			line := lines[link.LineNumber-1]
+			lines[link.LineNumber-1] = line[:link.TargetStart] + replacement + line[link.TargetEnd:]
+			changed = true
+		}
+		if changed {
+			rewrites = append(rewrites, linkRewrite{Path: newSrc, Content: strings.Join(lines, "\n"), Hash: noteHash(data)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rewrites, nil
+}
+
+// applyLinkRewrites writes planned rewrites after the rename and returns the
+// paths of the notes it changed and of those it skipped because they were
+// edited after the rewrite was planned, so the edit is not overwritten.
+func (s *Server) applyLinkRewrites(rewrites []linkRewrite) ([]string, []string) {
+	updated := make([]string, 0, len(rewrites))
+	var skipped []string
+	for _, rewrite := range rewrites {
+		if s.applyLinkRewrite(rewrite) {
+			updated = append(updated, rewrite.Path)
+		} else {
+			skipped = append(skipped, rewrite.Path)
+		}
+	}
+	sort.Strings(updated)
+	sort.Strings(skipped)
+	return updated, skipped
+}
+
+// applyLinkRewrite writes one planned rewrite while holding the note's lock,
+// reporting false when the note changed since the plan or cannot be written.
+func (s *Server) applyLinkRewrite(rewrite linkRewrite) bool {
+	defer s.lockNote(rewrite.Path)()
+	absPath := filepath.Join(s.notesDir, filepath.FromSlash(rewrite.Path))
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		s.logger.Warn("unable to read note for link update", "path", rewrite.Path, "error", err)
+		return false
+	}
+	if noteHash(current) != rewrite.Hash {
+		s.logger.Warn("note changed before link update", "path", rewrite.Path)
+		return false
+	}
+	s.snapshotNote(rewrite.Path, current, "links")
+	if err := writeFileAtomic(absPath, []byte(rewrite.Content), 0o644); err != nil {
+		s.logger.Warn("unable to update links", "path", rewrite.Path, "error", err)
+		return false
+	}
+	s.indexPath(rewrite.Path)
+	return true
+}
+
+func rewriteWikiTarget(inner, src string, index *linkIndex, moved map[string]string, nameCounts map[string]int) (string, bool) {
+	wiki := parseWikiLink(inner)
+	if wiki.Target == "" {
+		return "", false
+	}
+	candidates := index.resolve(wiki.Target, src)
+	if len(candidates) != 1 {
+		return "", false
+	}
+	newPath, ok := moved[candidates[0]]
+	if !ok {
+		return "", false
+	}
+
+	newTarget := strings.TrimSuffix(newPath, path.Ext(newPath))
+	if !strings.Contains(wiki.Target, "/") {
+		if linkKey(wiki.Target) != linkKey(path.Base(candidates[0])) {
+			// Resolved through a frontmatter alias, which moves with the note.
+			return "", false
+		}
+		newName := path.Base(newTarget)
+		if linkKey(newName) == linkKey(wiki.Target) {
+			return "", false
+		}
+		if nameCounts[linkKey(newName)] == 1 {
+			newTarget = newName
+		}
+	}
+	if isMarkdown(wiki.Target) {
+		newTarget += path.Ext(newPath)
+	}
+
+	end := strings.IndexAny(inner, "#|")
+	if end == -1 {
+		end = len(inner)
+	}
+	return strings.Replace(inner[:end], wiki.Target, newTarget, 1) + inner[end:], true
+}
+
+func rewriteMarkdownHref(href, oldSrc, newSrc string, moved map[string]string, exists func(string) bool) (string, bool) {
+	oldTarget, ok := resolveMarkdownHref(oldSrc, href)
+	if !ok {
+		return "", false
+	}
+	newTarget, targetMoved := moved[oldTarget]
+	if !targetMoved {
+		if oldSrc == newSrc || !exists(oldTarget) {
+			return "", false
+		}
+		newTarget = oldTarget
+	}
+	if resolved, ok := resolveMarkdownHref(newSrc, href); ok && resolved == newTarget {
+		return "", false
+	}
+
+	if strings.HasPrefix(href, "/files?") {
+		if !targetMoved {
+			return "", false
+		}
+		return "/files?path=" + strings.ReplaceAll(url.QueryEscape(newTarget), "%2F", "/"), true
+	}
+
+	base, fragment, hasFragment := strings.Cut(href, "#")
+	var rewritten string
+	if strings.HasPrefix(base, "/") {
+		rewritten = "/" + newTarget
+	} else {
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(newSrc)), filepath.FromSlash(newTarget))
+		if err != nil {
+			return "", false
+		}
+		rewritten = filepath.ToSlash(rel)
+	}
+	if unescaped, err := url.PathUnescape(base); err == nil && unescaped != base {
+		rewritten = (&url.URL{Path: rewritten}).EscapedPath()
+	}
+	if hasFragment {
+		rewritten += "#" + fragment
+	}
+	return rewritten, true
+}
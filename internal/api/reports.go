@@ -0,0 +1,178 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type BrokenLink struct {
+	Path       string   `json:"path"`
+	LineNumber int      `json:"lineNumber"`
+	Kind       string   `json:"kind"`
+	Target     string   `json:"target"`
+	Reason     string   `json:"reason"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+type LinkReport struct {
+	BrokenLinks       []BrokenLink `json:"brokenLinks"`
+	BrokenFileRefs    []BrokenLink `json:"brokenFileRefs"`
+	OrphanNotes       []string     `json:"orphanNotes"`
+	UnusedAttachments []string     `json:"unusedAttachments"`
+}
+
+func (s *Server) handleLinkReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.buildLinkReport()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to build link report")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) buildLinkReport() (LinkReport, error) {
+	report := LinkReport{
+		BrokenLinks:       []BrokenLink{},
+		BrokenFileRefs:    []BrokenLink{},
+		OrphanNotes:       []string{},
+		UnusedAttachments: []string{},
+	}
+
+	index, err := s.buildLinkIndex()
+	if err != nil {
+		return report, err
+	}
+	attachments, err := s.listAttachments()
+	if err != nil {
+		return report, err
+	}
+	attachmentsByName := make(map[string][]string)
+	for relPath := range attachments {
+		name := strings.ToLower(path.Base(relPath))
+		attachmentsByName[name] = append(attachmentsByName[name], relPath)
+	}
+
+	referenced := make(map[string]bool)
+	linked := make(map[string]bool)
+	exists := func(relPath string) bool {
+		_, err := os.Stat(filepath.Join(s.notesDir, filepath.FromSlash(relPath)))
+		return err == nil
+	}
+
+	err = s.walkNotes(func(rel string, data []byte) error {
+		for _, link := range extractNoteLinks(string(data)) {
+			broken := BrokenLink{Path: rel, LineNumber: link.LineNumber, Target: link.Target}
+			switch link.Kind {
+			case "wiki":
+				wiki := parseWikiLink(link.Target)
+				if wiki.Target == "" {
+					continue
+				}
+				if path.Ext(wiki.Target) != "" && !isMarkdown(wiki.Target) {
+					// Embeds such as ![[diagram.png]] point at attachments.
+					if relPath, ok := attachments[strings.ToLower(strings.TrimPrefix(wiki.Target, "/"))]; ok {
+						referenced[relPath] = true
+						continue
+					}
+					matches := attachmentsByName[strings.ToLower(path.Base(wiki.Target))]
+					if len(matches) > 0 {
+						for _, relPath := range matches {
+							referenced[relPath] = true
+						}
+						continue
+					}
+				}
+				candidates := index.resolve(wiki.Target, rel)
+				broken.Kind = "wiki"
+				switch len(candidates) {
+				case 0:
+					broken.Reason = "missing"
+				case 1:
+					if candidates[0] != rel {
+						linked[rel] = true
+						linked[candidates[0]] = true
+					}
+					continue
+				default:
+					broken.Reason = "ambiguous"
+					broken.Candidates = candidates
+				}
+				report.BrokenLinks = append(report.BrokenLinks, broken)
+			case "markdown":
+				target, ok := resolveMarkdownHref(rel, link.Target)
+				if !ok {
+					continue
+				}
+				if exists(target) {
+					referenced[target] = true
+					if note := index.resolveLink(rel, link); note != "" && note != rel {
+						linked[rel] = true
+						linked[note] = true
+					}
+					continue
+				}
+				broken.Reason = "missing"
+				if strings.HasPrefix(link.Target, "/files?") {
+					broken.Kind = "file"
+					report.BrokenFileRefs = append(report.BrokenFileRefs, broken)
+					continue
+				}
+				broken.Kind = "markdown"
+				if link.Image {
+					broken.Kind = "image"
+				}
+				report.BrokenLinks = append(report.BrokenLinks, broken)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, relPath := range index.paths {
+		if !linked[relPath] {
+			report.OrphanNotes = append(report.OrphanNotes, relPath)
+		}
+	}
+	for _, relPath := range attachments {
+		if !referenced[relPath] {
+			report.UnusedAttachments = append(report.UnusedAttachments, relPath)
+		}
+	}
+	sort.Strings(report.UnusedAttachments)
+	return report, nil
+}
+
+// listAttachments returns the images, PDFs, and CSV files under the notes
+// directory keyed by their lowercased notes-relative path.
+func (s *Server) listAttachments() (map[string]string, error) {
+	attachments := make(map[string]string)
+	err := filepath.WalkDir(s.notesDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if s.isReservedDir(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if isIgnoredFile(name) || !(isImage(name) || isPDF(name) || isCSV(name)) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.notesDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		attachments[strings.ToLower(rel)] = rel
+		return nil
+	})
+	return attachments, err
+}
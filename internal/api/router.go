@@ -6,7 +6,14 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// NewRouter returns the API routes for notesDir. Use New when the server
+// needs to be closed on shutdown.
 func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
+	return New(notesDir, logger...).Routes()
+}
+
+// New returns the API server for notesDir.
+func New(notesDir string, logger ...*slog.Logger) *Server {
 	var baseLogger *slog.Logger
 	if len(logger) > 0 && logger[0] != nil {
 		baseLogger = logger[0]
@@ -16,8 +23,20 @@ func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
 	s := &Server{
 		notesDir: notesDir,
 		logger:   baseLogger.With("component", "api"),
+		search:   newSearchIndex(),
+		recent:   &recentNotes{},
 	}
+	s.warnReservedDirs()
+	return s
+}
+
+// Close stops background work and saves the search index. Call it once the
+// routes no longer serve requests.
+func (s *Server) Close() {
+	s.closeSearchIndex()
+}
 
+func (s *Server) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Get("/health", s.handleHealth)
 	r.Get("/tree", s.handleTree)
@@ -25,18 +44,40 @@ func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
 	r.Post("/notes", s.handleCreateNote)
 	r.Patch("/notes", s.handleUpdateNote)
 	r.Patch("/notes/rename", s.handleRenameNote)
+	r.Patch("/notes/frontmatter", s.handleUpdateFrontmatter)
 	r.Delete("/notes", s.handleDeleteNote)
+	r.Get("/notes/history", s.handleNoteHistory)
+	r.Get("/notes/revision", s.handleNoteRevision)
+	r.Get("/notes/diff", s.handleNoteDiff)
+	r.Post("/notes/restore", s.handleNoteRestore)
+	r.Get("/notes/backlinks", s.handleBacklinks)
+	r.Get("/notes/quick", s.handleQuickSwitch)
 	r.Get("/files", s.handleGetFile)
 	r.Get("/search", s.handleSearch)
+	r.Post("/search/replace", s.handleSearchReplace)
+	r.Get("/searches", s.handleSavedSearchesList)
+	r.Post("/searches", s.handleSavedSearchCreate)
+	r.Patch("/searches", s.handleSavedSearchUpdate)
+	r.Delete("/searches", s.handleSavedSearchDelete)
+	r.Get("/links/resolve", s.handleLinksResolve)
 	r.Get("/tags", s.handleTags)
+	r.Post("/tags/rename", s.handleTagRename)
+	r.Get("/graph", s.handleGraph)
+	r.Get("/reports/links", s.handleLinkReport)
 	r.Get("/settings", s.handleSettingsGet)
 	r.Patch("/settings", s.handleSettingsUpdate)
 	r.Post("/folders", s.handleCreateFolder)
 	r.Patch("/folders", s.handleRenameFolder)
 	r.Delete("/folders", s.handleDeleteFolder)
 	r.Get("/tasks", s.handleTasksList)
+	r.Post("/tasks", s.handleTaskCreate)
+	r.Patch("/tasks", s.handleTaskUpdate)
+	r.Delete("/tasks", s.handleTaskDelete)
 	r.Patch("/tasks/toggle", s.handleTasksToggle)
 	r.Patch("/tasks/archive", s.handleTasksArchive)
+	r.Get("/trash", s.handleTrashList)
+	r.Post("/trash/restore", s.handleTrashRestore)
+	r.Delete("/trash", s.handleTrashEmpty)
 
 	return r
 }
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+const (
+	savedSearchesFileName = "searches.json"
+	savedSearchPathPrefix = "search:"
+)
+
+// SavedSearch is a named search with the same options as GET /search.
+type SavedSearch struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Query         string   `json:"query"`
+	Mode          string   `json:"mode,omitempty"`
+	CaseSensitive bool     `json:"caseSensitive,omitempty"`
+	Types         []string `json:"types,omitempty"`
+}
+
+type savedSearchesFile struct {
+	Version  int           `json:"version"`
+	Searches []SavedSearch `json:"searches"`
+}
+
+type SavedSearchPayload struct {
+	ID            string    `json:"id,omitempty"`
+	Name          *string   `json:"name,omitempty"`
+	Query         *string   `json:"query,omitempty"`
+	Mode          *string   `json:"mode,omitempty"`
+	CaseSensitive *bool     `json:"caseSensitive,omitempty"`
+	Types         *[]string `json:"types,omitempty"`
+}
+
+func (s *Server) handleSavedSearchesList(w http.ResponseWriter, r *http.Request) {
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load saved searches")
+		return
+	}
+	writeJSON(w, http.StatusOK, searches)
+}
+
+func (s *Server) handleSavedSearchCreate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[SavedSearchPayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.ID != "" {
+		writeError(w, http.StatusBadRequest, "id is assigned by the server")
+		return
+	}
+	var search SavedSearch
+	applySavedSearchPayload(&search, payload)
+	if err := s.validateSavedSearch(&search); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.savedSearchesMu.Lock()
+	defer s.savedSearchesMu.Unlock()
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load saved searches")
+		return
+	}
+	search.ID = uniqueSavedSearchID(search.Name, searches)
+	searches = append(searches, search)
+	if err := s.saveSavedSearches(searches); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to save saved searches")
+		return
+	}
+
+	s.logger.Info("saved search created", "id", search.ID)
+	writeJSON(w, http.StatusCreated, search)
+}
+
+func (s *Server) handleSavedSearchUpdate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[SavedSearchPayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(payload.ID) == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	s.savedSearchesMu.Lock()
+	defer s.savedSearchesMu.Unlock()
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load saved searches")
+		return
+	}
+	index := findSavedSearch(searches, payload.ID)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "saved search not found")
+		return
+	}
+
+	search := searches[index]
+	applySavedSearchPayload(&search, payload)
+	if err := s.validateSavedSearch(&search); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	searches[index] = search
+	if err := s.saveSavedSearches(searches); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to save saved searches")
+		return
+	}
+
+	s.logger.Info("saved search updated", "id", search.ID)
+	writeJSON(w, http.StatusOK, search)
+}
+
+func (s *Server) handleSavedSearchDelete(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	s.savedSearchesMu.Lock()
+	defer s.savedSearchesMu.Unlock()
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load saved searches")
+		return
+	}
+	index := findSavedSearch(searches, id)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "saved search not found")
+		return
+	}
+	searches = append(searches[:index], searches[index+1:]...)
+	if err := s.saveSavedSearches(searches); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to save saved searches")
+		return
+	}
+
+	s.logger.Info("saved search deleted", "id", id)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) handleSavedSearchTree(w http.ResponseWriter, id string) {
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load saved searches")
+		return
+	}
+	index := findSavedSearch(searches, id)
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "saved search not found")
+		return
+	}
+	search := searches[index]
+	if err := s.validateSavedSearch(&search); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	node, err := s.savedSearchTree(search)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to run saved search")
+		return
+	}
+	writeJSON(w, http.StatusOK, node)
+}
+
+// savedSearchTree runs a saved search and returns it as a virtual folder
+// holding the matching notes. Task hits list the note they are in.
+func (s *Server) savedSearchTree(search SavedSearch) (TreeNode, error) {
+	node := TreeNode{Name: search.Name, Path: savedSearchPathPrefix + search.ID, Type: "search"}
+	types, err := parseSearchTypes(strings.Join(search.Types, ","))
+	if err != nil {
+		return node, err
+	}
+	run, _, err := s.prepareSearch(search.Query, search.Mode, search.CaseSensitive, types)
+	if err != nil {
+		return node, err
+	}
+	hits, err := run()
+	if err != nil {
+		return node, err
+	}
+	seen := make(map[string]bool, len(hits))
+	node.Children = []TreeNode{}
+	for _, hit := range hits {
+		if seen[hit.Path] {
+			continue
+		}
+		seen[hit.Path] = true
+		node.Children = append(node.Children, TreeNode{Name: filepath.Base(hit.Path), Path: hit.Path, Type: "file"})
+	}
+	return node, nil
+}
+
+// savedSearchNodes lists the saved searches as virtual folders without
+// running them; GET /tree?search=<id> computes their contents.
+func (s *Server) savedSearchNodes() ([]TreeNode, error) {
+	searches, err := s.loadSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]TreeNode, 0, len(searches))
+	for _, search := range searches {
+		nodes = append(nodes, TreeNode{Name: search.Name, Path: savedSearchPathPrefix + search.ID, Type: "search"})
+	}
+	return nodes, nil
+}
+
+func applySavedSearchPayload(search *SavedSearch, payload SavedSearchPayload) {
+	if payload.Name != nil {
+		search.Name = strings.TrimSpace(*payload.Name)
+	}
+	if payload.Query != nil {
+		search.Query = strings.TrimSpace(*payload.Query)
+	}
+	if payload.Mode != nil {
+		search.Mode = strings.TrimSpace(*payload.Mode)
+	}
+	if payload.CaseSensitive != nil {
+		search.CaseSensitive = *payload.CaseSensitive
+	}
+	if payload.Types != nil {
+		search.Types = *payload.Types
+	}
+}
+
+// validateSavedSearch checks that search would run as a GET /search request
+// and normalizes its types.
+func (s *Server) validateSavedSearch(search *SavedSearch) error {
+	if search.Name == "" {
+		return errors.New("name is required")
+	}
+	if search.Query == "" {
+		return errors.New("query is required")
+	}
+	types, err := parseSearchTypes(strings.Join(search.Types, ","))
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.prepareSearch(search.Query, search.Mode, search.CaseSensitive, types); err != nil {
+		return err
+	}
+	search.Types = nil
+	for _, kind := range []string{"note", "template", "task"} {
+		if types[kind] {
+			search.Types = append(search.Types, kind)
+		}
+	}
+	return nil
+}
+
+// uniqueSavedSearchID derives a readable id from name, adding a number when
+// another search already uses it.
+func uniqueSavedSearchID(name string, searches []SavedSearch) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	base := strings.TrimSuffix(b.String(), "-")
+	if base == "" {
+		base = "search"
+	}
+	id := base
+	for n := 2; findSavedSearch(searches, id) >= 0; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+func findSavedSearch(searches []SavedSearch, id string) int {
+	for i, search := range searches {
+		if search.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) savedSearchesFilePath() string {
+	return filepath.Join(s.notesDir, savedSearchesFileName)
+}
+
+func (s *Server) loadSavedSearches() ([]SavedSearch, error) {
+	data, err := os.ReadFile(s.savedSearchesFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []SavedSearch{}, nil
+		}
+		return nil, err
+	}
+	var file savedSearchesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Searches == nil {
+		file.Searches = []SavedSearch{}
+	}
+	return file.Searches, nil
+}
+
+func (s *Server) saveSavedSearches(searches []SavedSearch) error {
+	data, err := json.MarshalIndent(savedSearchesFile{Version: 1, Searches: searches}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return writeFileAtomic(s.savedSearchesFilePath(), data, 0o644)
+}
@@ -0,0 +1,462 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	indexDirName            = ".index"
+	searchIndexFile         = "search.json"
+	searchIndexVersion      = 5
+	defaultSearchLimit      = 100
+	maxSearchLimit          = 500
+	searchIndexSaveInterval = 30 * time.Second
+	searchReconcileInterval = time.Minute
+	searchNameBoost         = 3.0
+	searchPrefixWeight      = 0.5
+	searchSubstringWeight   = 0.25
+)
+
+// searchIndex is an inverted index over note and template file names and
+// contents. It is loaded lazily on the first search and kept current by the
+// note handlers. Edits made outside the app are picked up by reconciling
+// against file modification times when the index loads and then at most once
+// every searchReconcileInterval, so a search does not walk the whole vault.
+// Changes are saved shortly after they happen rather than on the next search.
+type searchIndex struct {
+	mu            sync.Mutex
+	loaded        bool
+	dirty         bool
+	lastReconcile time.Time
+	saveTimer     *time.Timer
+	closed        bool
+	docs          map[string]*indexedDoc
+	postings      map[string]map[string]int
+	terms         []string
+	sorted        bool
+}
+
+type indexedDoc struct {
+	Path      string         `json:"path"`
+	Type      string         `json:"type"`
+	Modified  int64          `json:"modified"`
+	Size      int64          `json:"size"`
+	Terms     map[string]int `json:"terms"`
+	NameTerms []string       `json:"nameTerms"`
+	Tags      []string       `json:"tags,omitempty"`
+	Tasks     []indexedTask  `json:"tasks,omitempty"`
+}
+
+// indexedTask keeps the parts of a task that /search matches on, so task
+// search does not have to reparse every note.
+type indexedTask struct {
+	LineNumber int      `json:"lineNumber"`
+	Line       string   `json:"line"`
+	Text       string   `json:"text"`
+	Project    string   `json:"project,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Mentions   []string `json:"mentions,omitempty"`
+}
+
+// words returns the task's searchable words: its text, project, tags, and
+// mentions.
+func (t indexedTask) words() []string {
+	words := splitWords(t.Text)
+	words = append(words, splitWords(t.Project)...)
+	for _, tag := range t.Tags {
+		words = append(words, splitWords(tag)...)
+	}
+	for _, mention := range t.Mentions {
+		words = append(words, splitWords(mention)...)
+	}
+	return words
+}
+
+type searchIndexSnapshot struct {
+	Version int           `json:"version"`
+	Docs    []*indexedDoc `json:"docs"`
+}
+
+type searchHit struct {
+	Path  string
+	Type  string
+	Score float64
+	Task  *indexedTask
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		docs:     make(map[string]*indexedDoc),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// tokenize lowercases text and splits it into runs of letters and digits.
+func tokenize(text string) []string {
+	return splitWords(strings.ToLower(text))
+}
+
+// splitWords splits text into runs of letters and digits, keeping case.
+func splitWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newIndexedDoc(relPath string, info os.FileInfo, content []byte) *indexedDoc {
+	doc := &indexedDoc{
+		Path:     relPath,
+		Type:     "note",
+		Modified: info.ModTime().UnixNano(),
+		Size:     info.Size(),
+		Terms:    make(map[string]int),
+		Tags:     extractNoteTags(string(content)),
+	}
+	if isTemplate(relPath) {
+		doc.Type = "template"
+	}
+	doc.NameTerms = tokenize(path.Base(relPath))
+	for _, term := range doc.NameTerms {
+		doc.Terms[term]++
+	}
+	for _, term := range tokenize(string(content)) {
+		doc.Terms[term]++
+	}
+	if doc.Type == "note" {
+		lines := strings.Split(string(content), "\n")
+		for _, todo := range parseTodoLines(string(content), dueDateBase(relPath)) {
+			doc.Tasks = append(doc.Tasks, indexedTask{
+				LineNumber: todo.LineNumber,
+				Line:       strings.TrimSuffix(lines[todo.LineNumber-1], "\r"),
+				Text:       todo.Text,
+				Project:    todo.Project,
+				Tags:       todo.Tags,
+				Mentions:   todo.Mentions,
+			})
+		}
+	}
+	return doc
+}
+
+func (idx *searchIndex) add(doc *indexedDoc) {
+	idx.remove(doc.Path)
+	idx.docs[doc.Path] = doc
+	for term, count := range doc.Terms {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			idx.postings[term] = docs
+			idx.sorted = false
+		}
+		docs[doc.Path] = count
+	}
+	idx.dirty = true
+}
+
+func (idx *searchIndex) remove(relPath string) {
+	doc, ok := idx.docs[relPath]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		docs := idx.postings[term]
+		delete(docs, relPath)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+			idx.sorted = false
+		}
+	}
+	delete(idx.docs, relPath)
+	idx.dirty = true
+}
+
+// removePrefix drops relPath and, when it is a folder, every note under it.
+func (idx *searchIndex) removePrefix(relPath string) {
+	for docPath := range idx.docs {
+		if docPath == relPath || strings.HasPrefix(docPath, relPath+"/") {
+			idx.remove(docPath)
+		}
+	}
+}
+
+// matchTerms returns the indexed terms starting with token.
+func (idx *searchIndex) matchTerms(token string) []string {
+	if !idx.sorted {
+		idx.terms = idx.terms[:0]
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+		idx.sorted = true
+	}
+	var matches []string
+	for i := sort.SearchStrings(idx.terms, token); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], token); i++ {
+		matches = append(matches, idx.terms[i])
+	}
+	return matches
+}
+
+// substringTerms returns the indexed terms containing token anywhere but at
+// the start, such as roadmap for map.
+func (idx *searchIndex) substringTerms(token string) []string {
+	var matches []string
+	for term := range idx.postings {
+		if len(term) > len(token) && !strings.HasPrefix(term, token) && strings.Contains(term, token) {
+			matches = append(matches, term)
+		}
+	}
+	return matches
+}
+
+// tokenScores scores the notes containing a word equal to, starting with, or
+// containing token using a tf-idf style weight. Prefix matches count for
+// less, other substring matches for less still, and matches in the file name
+// count extra.
+func (idx *searchIndex) tokenScores(token string) map[string]float64 {
+	total := float64(len(idx.docs))
+	scores := make(map[string]float64)
+	prefixed := idx.matchTerms(token)
+	for i, term := range append(prefixed, idx.substringTerms(token)...) {
+		docs := idx.postings[term]
+		weight := math.Log(1 + total/float64(len(docs)))
+		if i >= len(prefixed) {
+			weight *= searchSubstringWeight
+		} else if term != token {
+			weight *= searchPrefixWeight
+		}
+		for docPath, count := range docs {
+			score := weight * float64(count) / float64(count+1)
+			if containsString(idx.docs[docPath].NameTerms, term) {
+				score += weight * searchNameBoost
+			}
+			if score > scores[docPath] {
+				scores[docPath] = score
+			}
+		}
+	}
+	return scores
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) searchIndexPath() string {
+	return filepath.Join(s.notesDir, indexDirName, searchIndexFile)
+}
+
+// searchNotes runs evaluate while holding the index lock. The first search
+// loads the index and reconciles it with the notes directory, saving the
+// result right away; later searches reconcile only once the last reconcile is
+// searchReconcileInterval old.
+func (s *Server) searchNotes(evaluate func() []searchHit) ([]searchHit, error) {
+	idx := s.search
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.loaded {
+		s.loadSearchIndex()
+		if err := s.reconcileSearchIndex(); err != nil {
+			return nil, err
+		}
+		if idx.dirty {
+			s.saveSearchIndex()
+		}
+	} else if timeNow().Sub(idx.lastReconcile) >= searchReconcileInterval {
+		if err := s.reconcileSearchIndex(); err != nil {
+			return nil, err
+		}
+		s.scheduleSearchIndexSave()
+	}
+	return evaluate(), nil
+}
+
+// scheduleSearchIndexSave saves the index searchIndexSaveInterval after the
+// first unsaved change, so a burst of edits is written once. The caller holds
+// the index lock.
+func (s *Server) scheduleSearchIndexSave() {
+	idx := s.search
+	if !idx.dirty || idx.saveTimer != nil || idx.closed {
+		return
+	}
+	idx.saveTimer = time.AfterFunc(searchIndexSaveInterval, func() {
+		idx.mu.Lock()
+		defer idx.mu.Unlock()
+		idx.saveTimer = nil
+		if idx.dirty {
+			s.saveSearchIndex()
+		}
+	})
+}
+
+// closeSearchIndex stops the pending save and writes unsaved changes now.
+// Changes after it are not scheduled for saving.
+func (s *Server) closeSearchIndex() {
+	idx := s.search
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.closed = true
+	if idx.saveTimer != nil {
+		idx.saveTimer.Stop()
+		idx.saveTimer = nil
+	}
+	if idx.loaded && idx.dirty {
+		s.saveSearchIndex()
+	}
+}
+
+// loadSearchIndex reads the persisted index if there is one. A missing or
+// unreadable file just means the next reconcile indexes every note.
+func (s *Server) loadSearchIndex() {
+	idx := s.search
+	idx.loaded = true
+	data, err := os.ReadFile(s.searchIndexPath())
+	if err != nil {
+		return
+	}
+	var snapshot searchIndexSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.Version != searchIndexVersion {
+		s.logger.Warn("ignoring search index", "path", s.searchIndexPath(), "error", err)
+		return
+	}
+	for _, doc := range snapshot.Docs {
+		if doc != nil && doc.Terms != nil {
+			idx.add(doc)
+		}
+	}
+	idx.dirty = false
+}
+
+func (s *Server) saveSearchIndex() {
+	idx := s.search
+	snapshot := searchIndexSnapshot{Version: searchIndexVersion, Docs: make([]*indexedDoc, 0, len(idx.docs))}
+	for _, doc := range idx.docs {
+		snapshot.Docs = append(snapshot.Docs, doc)
+	}
+	data, err := json.Marshal(snapshot)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.searchIndexPath()), 0o755)
+	}
+	if err == nil {
+		err = writeFileAtomic(s.searchIndexPath(), data, 0o644)
+	}
+	if err != nil {
+		s.logger.Warn("unable to save search index", "error", err)
+		return
+	}
+	idx.dirty = false
+}
+
+// reconcileSearchIndex stats every note and reindexes the ones whose size or
+// modification time changed since they were indexed.
+func (s *Server) reconcileSearchIndex() error {
+	idx := s.search
+	seen := make(map[string]bool, len(idx.docs))
+	err := filepath.WalkDir(s.notesDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if s.isReservedDir(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isIgnoredFile(d.Name()) || !isNoteFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.notesDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if doc, ok := idx.docs[rel]; ok && doc.Modified == info.ModTime().UnixNano() && doc.Size == info.Size() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil
+		}
+		idx.add(newIndexedDoc(rel, info, data))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for docPath := range idx.docs {
+		if !seen[docPath] {
+			idx.remove(docPath)
+		}
+	}
+	idx.lastReconcile = timeNow()
+	return nil
+}
+
+// indexPath reindexes the note at relPath, or every note under it when it is
+// a folder. It does nothing until the index has been loaded by a search.
+func (s *Server) indexPath(relPath string) {
+	idx := s.search
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if !idx.loaded {
+		return
+	}
+	absPath := filepath.Join(s.notesDir, filepath.FromSlash(relPath))
+	err := filepath.WalkDir(absPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || isIgnoredFile(d.Name()) || !isNoteFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.notesDir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		idx.add(newIndexedDoc(filepath.ToSlash(rel), info, data))
+		return nil
+	})
+	if err != nil {
+		s.logger.Warn("unable to index note", "path", relPath, "error", err)
+	}
+	s.scheduleSearchIndexSave()
+}
+
+// unindexPath drops the note at relPath, or every note under it when it is a
+// folder.
+func (s *Server) unindexPath(relPath string) {
+	idx := s.search
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.loaded {
+		idx.removePrefix(relPath)
+		s.scheduleSearchIndexSave()
+	}
+}
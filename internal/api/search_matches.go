@@ -0,0 +1,227 @@
+package api
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxSearchMatchesPerNote = 5
+
+type SearchMatch struct {
+	LineNumber int          `json:"lineNumber"`
+	Snippet    string       `json:"snippet"`
+	Ranges     []MatchRange `json:"ranges"`
+}
+
+// MatchRange marks a highlighted part of a snippet in characters (Unicode
+// code points), end exclusive.
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// lineWord is a run of letters and digits in a line, as written and
+// lowercased, with its byte offsets in the line.
+type lineWord struct {
+	raw   string
+	text  string
+	start int
+	end   int
+}
+
+func (w lineWord) compareText(caseSensitive bool) string {
+	if caseSensitive {
+		return w.raw
+	}
+	return w.text
+}
+
+// findSearchMatches returns up to maxSearchMatchesPerNote lines of content in
+// which match finds something, along with the number of matches in the whole
+// note.
+func findSearchMatches(content string, match func(string) [][2]int) ([]SearchMatch, int) {
+	var matches []SearchMatch
+	total := 0
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		ranges := match(line)
+		if len(ranges) == 0 {
+			continue
+		}
+		total += len(ranges)
+		if len(matches) < maxSearchMatchesPerNote {
+			snippet, snippetRanges := searchSnippet(line, ranges)
+			matches = append(matches, SearchMatch{LineNumber: i + 1, Snippet: snippet, Ranges: snippetRanges})
+		}
+	}
+	return matches, total
+}
+
+// lineWords splits line into words the same way tokenize does.
+func lineWords(line string) []lineWord {
+	var words []lineWord
+	start := -1
+	for i, r := range line {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start == -1 {
+			start = i
+		} else if !isWord && start != -1 {
+			words = append(words, lineWord{raw: line[start:i], text: strings.ToLower(line[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		words = append(words, lineWord{raw: line[start:], text: strings.ToLower(line[start:]), start: start, end: len(line)})
+	}
+	return words
+}
+
+// wordMatches returns the byte ranges of the words that start with one of
+// tokens. Each range covers the matched prefix, not the whole word. Unless
+// caseSensitive is set, tokens must be lowercase.
+func wordMatches(words []lineWord, line string, tokens []string, caseSensitive bool) [][2]int {
+	var ranges [][2]int
+	for _, word := range words {
+		text := word.compareText(caseSensitive)
+		best := 0
+		for _, token := range tokens {
+			if n := utf8.RuneCountInString(token); n > best && strings.HasPrefix(text, token) {
+				best = n
+			}
+		}
+		if best > 0 {
+			ranges = append(ranges, [2]int{word.start, advanceRunes(line, word.start, best, word.end)})
+		}
+	}
+	return ranges
+}
+
+// substringMatches returns the byte ranges where one of tokens appears inside
+// a word, as map does in roadmap. Unless caseSensitive is set, tokens must be
+// lowercase; a line whose lowercase form changes length is not searched
+// then, since its offsets would not line up.
+func substringMatches(line string, tokens []string, caseSensitive bool) [][2]int {
+	text := line
+	if !caseSensitive {
+		text = strings.ToLower(line)
+		if len(text) != len(line) {
+			return nil
+		}
+	}
+	var ranges [][2]int
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], token)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			ranges = append(ranges, [2]int{start, start + len(token)})
+			offset = start + len(token)
+		}
+	}
+	return ranges
+}
+
+// phraseMatches returns the byte ranges where the words of phrase appear
+// consecutively.
+func phraseMatches(words []lineWord, phrase []string, caseSensitive bool) [][2]int {
+	if len(phrase) == 0 {
+		return nil
+	}
+	var ranges [][2]int
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		matched := true
+		for j, token := range phrase {
+			if words[i+j].compareText(caseSensitive) != token {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			ranges = append(ranges, [2]int{words[i].start, words[i+len(phrase)-1].end})
+		}
+	}
+	return ranges
+}
+
+// advanceRunes returns the byte offset n runes after start, capped at limit.
+func advanceRunes(line string, start, n, limit int) int {
+	offset := start
+	for ; n > 0 && offset < limit; n-- {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap.
+func mergeRanges(ranges [][2]int) [][2]int {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r[0] <= last[1] {
+			if r[1] > last[1] {
+				last[1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// searchSnippet trims line to the text around its first match, like
+// lineSnippet, and converts the byte ranges that fall inside the snippet to
+// character offsets within it.
+func searchSnippet(line string, ranges [][2]int) (string, []MatchRange) {
+	start, end := ranges[0][0], ranges[0][1]
+	from := start - snippetRadius
+	to := end + snippetRadius
+	prefix, suffix := "", ""
+	if from <= 0 {
+		from = 0
+		for from < start && (line[from] == ' ' || line[from] == '\t') {
+			from++
+		}
+	} else {
+		for from < start && !utf8.RuneStart(line[from]) {
+			from++
+		}
+		prefix = "…"
+	}
+	if to >= len(line) {
+		to = len(line)
+		for to > end && (line[to-1] == ' ' || line[to-1] == '\t') {
+			to--
+		}
+	} else {
+		for to > end && !utf8.RuneStart(line[to]) {
+			to--
+		}
+		suffix = "…"
+	}
+
+	shift := utf8.RuneCountInString(prefix)
+	snippetRanges := make([]MatchRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r[0] < from || r[1] > to {
+			continue
+		}
+		rangeStart := shift + utf8.RuneCountInString(line[from:r[0]])
+		snippetRanges = append(snippetRanges, MatchRange{
+			Start: rangeStart,
+			End:   rangeStart + utf8.RuneCountInString(line[r[0]:r[1]]),
+		})
+	}
+	return prefix + line[from:to] + suffix, snippetRanges
+}
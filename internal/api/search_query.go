@@ -0,0 +1,571 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const searchDateLayout = "2006-01-02"
+
+// searchQuery is a parsed /search query: every group must match, and a group
+// matches when any of its clauses does.
+type searchQuery struct {
+	groups        [][]searchClause
+	hasType       bool
+	caseSensitive bool
+	types         map[string]bool // kinds requested with types=, nil for the default
+}
+
+type searchClause struct {
+	kind   string // term, phrase, tag, path, type, or modified
+	value  string
+	tokens []string // lowercased words, for the index
+	words  []string // words as written, for case-sensitive matching
+	negate bool
+	after  time.Time // inclusive lower bound for modified, zero when open
+	before time.Time // exclusive upper bound for modified, zero when open
+}
+
+// parseSearchQuery parses the /search query language: plain words, "quoted
+// phrases", -negation, OR between two clauses, and the tag:, path: (or in:),
+// type:, and modified: filters. Clauses are ANDed; OR binds tighter, so
+// `a b OR c` means a and (b or c).
+func parseSearchQuery(input string) (*searchQuery, error) {
+	query := &searchQuery{}
+	pendingOr := false
+	lastWasClause := false
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negate = true
+			i++
+		}
+
+		var raw strings.Builder
+		phrase := runes[i] == '"'
+		quoted := false
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if runes[i] != '"' {
+				raw.WriteRune(runes[i])
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("unterminated quote")
+			}
+			raw.WriteString(string(runes[i+1 : end]))
+			quoted = true
+			i = end + 1
+		}
+		word := raw.String()
+
+		if word == "OR" && !quoted && !negate {
+			if !lastWasClause || pendingOr {
+				return nil, errors.New("OR must appear between two search terms")
+			}
+			pendingOr = true
+			continue
+		}
+
+		clause, err := parseSearchClause(word, phrase)
+		if err != nil {
+			return nil, err
+		}
+		clause.negate = negate
+		if clause.kind == "type" {
+			query.hasType = true
+		}
+		if (clause.kind == "term" || clause.kind == "phrase") && len(clause.tokens) == 0 {
+			// Punctuation on its own cannot match any indexed word.
+			if pendingOr {
+				return nil, errors.New("OR must appear between two search terms")
+			}
+			continue
+		}
+		if pendingOr {
+			last := len(query.groups) - 1
+			query.groups[last] = append(query.groups[last], clause)
+		} else {
+			query.groups = append(query.groups, []searchClause{clause})
+		}
+		pendingOr = false
+		lastWasClause = true
+	}
+	if pendingOr {
+		return nil, errors.New("OR must appear between two search terms")
+	}
+	if len(query.groups) > 0 && !query.hasPositive() {
+		return nil, errors.New("query needs at least one term that is not negated")
+	}
+	return query, nil
+}
+
+// hasPositive reports whether any clause is not negated. A query of only
+// negations would match every note and task with nothing to rank them by.
+func (q *searchQuery) hasPositive() bool {
+	for _, group := range q.groups {
+		for _, clause := range group {
+			if !clause.negate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseSearchClause(word string, phrase bool) (searchClause, error) {
+	if phrase {
+		return searchClause{kind: "phrase", value: word, tokens: tokenize(word), words: splitWords(word)}, nil
+	}
+	field, value, hasField := strings.Cut(word, ":")
+	field = strings.ToLower(field)
+	if !hasField || !isSearchField(field) {
+		return searchClause{kind: "term", value: word, tokens: tokenize(word), words: splitWords(word)}, nil
+	}
+	if strings.TrimSpace(value) == "" {
+		return searchClause{}, fmt.Errorf("%s: needs a value", field)
+	}
+
+	switch field {
+	case "tag":
+		return searchClause{kind: "tag", value: strings.TrimPrefix(value, "#")}, nil
+	case "path", "in":
+		folder := strings.Trim(filepath.ToSlash(strings.TrimSpace(value)), "/")
+		if folder != "" {
+			folder = path.Clean(folder)
+		}
+		return searchClause{kind: "path", value: strings.ToLower(folder)}, nil
+	case "type":
+		value = strings.ToLower(value)
+		if value != "note" && value != "template" && value != "task" {
+			return searchClause{}, fmt.Errorf("unknown type %q (use note, template, or task)", value)
+		}
+		return searchClause{kind: "type", value: value}, nil
+	default:
+		after, before, err := parseModifiedRange(value)
+		if err != nil {
+			return searchClause{}, err
+		}
+		return searchClause{kind: "modified", value: value, after: after, before: before}, nil
+	}
+}
+
+func isSearchField(field string) bool {
+	switch field {
+	case "tag", "path", "in", "type", "modified":
+		return true
+	}
+	return false
+}
+
+// parseModifiedRange accepts a day (2025-01-31), a comparison against a day
+// (>, >=, <, <=), or an inclusive range of days (2025-01-01..2025-01-31,
+// either end optional). Days are in local time.
+func parseModifiedRange(value string) (time.Time, time.Time, error) {
+	day := func(raw string) (time.Time, error) {
+		parsed, err := time.ParseInLocation(searchDateLayout, raw, time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid modified date %q (use YYYY-MM-DD)", raw)
+		}
+		return parsed, nil
+	}
+	nextDay := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		var after, before time.Time
+		if from != "" {
+			start, err := day(from)
+			if err != nil {
+				return after, before, err
+			}
+			after = start
+		}
+		if to != "" {
+			end, err := day(to)
+			if err != nil {
+				return after, before, err
+			}
+			before = nextDay(end)
+		}
+		if from == "" && to == "" {
+			return after, before, errors.New("modified range needs at least one date")
+		}
+		if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+			return after, before, errors.New("modified range ends before it starts")
+		}
+		return after, before, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		raw, ok := strings.CutPrefix(value, op)
+		if !ok {
+			continue
+		}
+		t, err := day(raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		switch op {
+		case ">=":
+			return t, time.Time{}, nil
+		case ">":
+			return nextDay(t), time.Time{}, nil
+		case "<=":
+			return time.Time{}, nextDay(t), nil
+		default:
+			return time.Time{}, t, nil
+		}
+	}
+
+	t, err := day(value)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return t, nextDay(t), nil
+}
+
+// parseSearchTypes reads the comma separated types= parameter. An empty value
+// returns nil, meaning the default kinds.
+func parseSearchTypes(raw string) (map[string]bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	types := make(map[string]bool)
+	for _, kind := range strings.Split(raw, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind != "note" && kind != "template" && kind != "task" {
+			return nil, fmt.Errorf("unknown type %q (use note, template, or task)", kind)
+		}
+		types[kind] = true
+	}
+	return types, nil
+}
+
+// taskSearchResult builds the result for a matching task, with its line as
+// the only match so the UI can jump to it.
+func taskSearchResult(hit searchHit, matchLine func(string) [][2]int) SearchResult {
+	task := hit.Task
+	result := SearchResult{
+		Path: hit.Path,
+		Name: task.Text,
+		Type: "task",
+		ID:   fmt.Sprintf("%s:%d", hit.Path, task.LineNumber),
+	}
+	ranges := matchLine(task.Line)
+	result.MatchCount = len(ranges)
+	if len(ranges) == 0 {
+		result.Matches = []SearchMatch{{LineNumber: task.LineNumber, Snippet: strings.TrimSpace(task.Line), Ranges: []MatchRange{}}}
+		return result
+	}
+	snippet, snippetRanges := searchSnippet(task.Line, ranges)
+	result.Matches = []SearchMatch{{LineNumber: task.LineNumber, Snippet: snippet, Ranges: snippetRanges}}
+	return result
+}
+
+// allows reports whether results of kind are wanted. Unless types= narrows
+// them, notes and tasks are searched, and templates only when the query has
+// a type: clause.
+func (q *searchQuery) allows(kind string) bool {
+	if q.types != nil {
+		return q.types[kind]
+	}
+	return kind != "template" || q.hasType
+}
+
+// evaluateSearch runs a parsed query against the index. Word clauses are
+// answered from the index; phrases and case-sensitive words read the note
+// only when the index shows every word is in it. Tasks are matched against
+// their indexed text, project, tags, and mentions. The word scores and the
+// note list are taken under the index lock; the notes are matched, and read
+// when needed, after it is released so disk reads do not hold up index
+// updates and other searches. Indexed notes are replaced, never changed, so
+// the listed ones stay valid.
+func (s *Server) evaluateSearch(query *searchQuery) ([]searchHit, error) {
+	idx := s.search
+	if len(query.groups) == 0 {
+		return nil, nil
+	}
+	tokenScores := make(map[string]map[string]float64)
+	var docs []*indexedDoc
+	_, err := s.searchNotes(func() []searchHit {
+		for _, group := range query.groups {
+			for _, clause := range group {
+				for _, token := range clause.tokens {
+					if _, ok := tokenScores[token]; !ok {
+						tokenScores[token] = idx.tokenScores(token)
+					}
+				}
+			}
+		}
+		docs = make([]*indexedDoc, 0, len(idx.docs))
+		for _, doc := range idx.docs {
+			docs = append(docs, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	scoresFor := func(token string) map[string]float64 { return tokenScores[token] }
+
+	var hits []searchHit
+	for _, doc := range docs {
+		if query.allows("task") {
+			for i := range doc.Tasks {
+				task := &doc.Tasks[i]
+				if query.matchGroups(func(c searchClause) (bool, float64) {
+					return c.matchTask(task, doc, query.caseSensitive), 0
+				}) {
+					hits = append(hits, searchHit{Path: doc.Path, Type: "task", Task: task})
+				}
+			}
+		}
+		if !query.allows(doc.Type) {
+			continue
+		}
+
+		var content *string
+		readContent := func() string {
+			if content == nil {
+				data, _ := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(doc.Path)))
+				text := string(data)
+				content = &text
+			}
+			return *content
+		}
+		total := 0.0
+		matched := query.matchGroups(func(c searchClause) (bool, float64) {
+			ok, score := c.match(doc, query.caseSensitive, scoresFor, readContent)
+			if ok {
+				total += score
+			}
+			return ok, score
+		})
+		if matched {
+			hits = append(hits, searchHit{Path: doc.Path, Type: doc.Type, Score: total})
+		}
+	}
+
+	sortSearchHits(hits)
+	return hits, nil
+}
+
+// matchGroups reports whether every group has a clause for which match
+// returns true.
+func (q *searchQuery) matchGroups(match func(searchClause) (bool, float64)) bool {
+	for _, group := range q.groups {
+		groupMatched := false
+		for _, clause := range group {
+			if ok, _ := match(clause); ok {
+				groupMatched = true
+			}
+		}
+		if !groupMatched {
+			return false
+		}
+	}
+	return true
+}
+
+// sortSearchHits orders notes and templates by score, best first, followed
+// by tasks in note and line order.
+func sortSearchHits(hits []searchHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if (a.Task == nil) != (b.Task == nil) {
+			return a.Task == nil
+		}
+		if a.Task != nil {
+			if a.Path != b.Path {
+				return a.Path < b.Path
+			}
+			return a.Task.LineNumber < b.Task.LineNumber
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Path < b.Path
+	})
+}
+
+func (c searchClause) match(doc *indexedDoc, caseSensitive bool, scoresFor func(string) map[string]float64, readContent func() string) (bool, float64) {
+	ok, score := false, 0.0
+	switch c.kind {
+	case "term", "phrase":
+		ok = true
+		for _, token := range c.tokens {
+			tokenScore := scoresFor(token)[doc.Path]
+			// Words match as prefixes, but phrases must match whole words.
+			if tokenScore == 0 || (c.kind == "phrase" && doc.Terms[token] == 0) {
+				ok = false
+				break
+			}
+			score += tokenScore
+		}
+		if ok && (c.kind == "phrase" || caseSensitive) {
+			ok = c.matchText(path.Base(doc.Path)+"\n"+readContent(), caseSensitive)
+		}
+		if !ok && c.kind == "term" && containsText(doc.Path, c.value, caseSensitive) {
+			// The path as written, such as meeting-notes.md or
+			// projects/q3, matches across the punctuation that splits words.
+			ok, score = true, searchNameBoost
+		}
+	case "tag":
+		ok = tagsContainFold(doc.Tags, c.value)
+	default:
+		ok = c.matchDoc(doc, doc.Type)
+	}
+	if c.negate {
+		return !ok, 0
+	}
+	return ok, score
+}
+
+// matchTask checks a clause against one task of doc. Words and phrases match
+// the task's own words and tags match its own tags; the other filters apply
+// to the note it is in.
+func (c searchClause) matchTask(task *indexedTask, doc *indexedDoc, caseSensitive bool) bool {
+	ok := false
+	switch c.kind {
+	case "term":
+		words := task.words()
+		tokens := c.words
+		if !caseSensitive {
+			tokens = c.tokens
+			for i, word := range words {
+				words[i] = strings.ToLower(word)
+			}
+		}
+		ok = true
+		for _, token := range tokens {
+			found := false
+			for _, word := range words {
+				if strings.HasPrefix(word, token) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				ok = false
+				break
+			}
+		}
+	case "phrase":
+		ok = c.matchText(task.Text, caseSensitive)
+	case "tag":
+		ok = tagsContainFold(task.Tags, c.value)
+	default:
+		ok = c.matchDoc(doc, "task")
+	}
+	if c.negate {
+		return !ok
+	}
+	return ok
+}
+
+// matchDoc checks the path:, type:, and modified: filters, with kind as the
+// result type.
+func (c searchClause) matchDoc(doc *indexedDoc, kind string) bool {
+	switch c.kind {
+	case "path":
+		return c.value == "" || strings.HasPrefix(strings.ToLower(doc.Path), c.value+"/")
+	case "type":
+		return kind == c.value
+	case "modified":
+		modified := time.Unix(0, doc.Modified)
+		return (c.after.IsZero() || !modified.Before(c.after)) && (c.before.IsZero() || modified.Before(c.before))
+	}
+	return false
+}
+
+// matchText checks a term or phrase clause against the note text itself,
+// which the lowercased index cannot answer for phrases or exact case.
+func (c searchClause) matchText(text string, caseSensitive bool) bool {
+	words := c.tokens
+	if caseSensitive {
+		words = c.words
+	}
+	lines := strings.Split(text, "\n")
+	if c.kind == "phrase" {
+		for _, line := range lines {
+			if len(phraseMatches(lineWords(line), words, caseSensitive)) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	for _, word := range words {
+		if !containsText(text, word, caseSensitive) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsText reports whether text contains value, ignoring case unless
+// caseSensitive is set.
+func containsText(text, value string, caseSensitive bool) bool {
+	if caseSensitive {
+		return strings.Contains(text, value)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(value))
+}
+
+// noteTitle returns the file name of a note without its extension.
+func noteTitle(relPath string) string {
+	base := path.Base(relPath)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// lineMatcher returns a function that finds the text to highlight in a line:
+// words starting with a positive search word, or failing that the search
+// words inside longer words, and occurrences of positive phrases.
+func (q *searchQuery) lineMatcher() func(string) [][2]int {
+	var tokens []string
+	var phrases [][]string
+	for _, group := range q.groups {
+		for _, clause := range group {
+			if clause.negate {
+				continue
+			}
+			words := clause.tokens
+			if q.caseSensitive {
+				words = clause.words
+			}
+			switch clause.kind {
+			case "term":
+				tokens = append(tokens, words...)
+			case "phrase":
+				phrases = append(phrases, words)
+			}
+		}
+	}
+	return func(line string) [][2]int {
+		words := lineWords(line)
+		ranges := wordMatches(words, line, tokens, q.caseSensitive)
+		if len(ranges) == 0 {
+			ranges = substringMatches(line, tokens, q.caseSensitive)
+		}
+		for _, phrase := range phrases {
+			ranges = append(ranges, phraseMatches(words, phrase, q.caseSensitive)...)
+		}
+		return mergeRanges(ranges)
+	}
+}
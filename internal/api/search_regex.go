@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+const (
+	maxRegexLength       = 1000
+	maxRegexInstructions = 20000
+	regexCompileTimeout  = 250 * time.Millisecond
+)
+
+// compileSearchRegex compiles a user supplied RE2 pattern, rejecting ones
+// that are too long, compile to too large a program, or take too long to
+// compile. A compile that times out cannot be stopped, so the length limit is
+// checked first to bound its work, and the result channel is buffered so the
+// goroutine can still finish and exit after nobody is waiting for it.
+func compileSearchRegex(pattern string, caseSensitive bool) (*regexp.Regexp, error) {
+	if len(pattern) > maxRegexLength {
+		return nil, fmt.Errorf("pattern is too long: the limit is %d bytes", maxRegexLength)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	type result struct {
+		re  *regexp.Regexp
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		parsed, err := syntax.Parse(pattern, syntax.Perl)
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			done <- result{err: fmt.Errorf("invalid pattern: %s: `%s`", syntaxErr.Code, syntaxErr.Expr)}
+			return
+		} else if err != nil {
+			done <- result{err: err}
+			return
+		}
+		prog, err := syntax.Compile(parsed.Simplify())
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		if len(prog.Inst) > maxRegexInstructions {
+			done <- result{err: fmt.Errorf("pattern is too complex: the limit is %d compiled instructions", maxRegexInstructions)}
+			return
+		}
+		re, err := regexp.Compile(pattern)
+		done <- result{re: re, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return res.re, nil
+	case <-time.After(regexCompileTimeout):
+		return nil, fmt.Errorf("pattern took too long to compile: the limit is %s", regexCompileTimeout)
+	}
+}
+
+// regexLineMatcher finds the non-empty matches of re in a line.
+func regexLineMatcher(re *regexp.Regexp) func(string) [][2]int {
+	return func(line string) [][2]int {
+		var ranges [][2]int
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[1] > loc[0] {
+				ranges = append(ranges, [2]int{loc[0], loc[1]})
+			}
+		}
+		return ranges
+	}
+}
+
+// searchRegex scans every note line by line for re. Notes are ranked by
+// their number of matches, with a match in the note name counting extra.
+// Tasks match when re matches their text. The note list and tasks come from
+// the index under its lock; the notes are read after it is released so a
+// long scan does not hold up note writes.
+func (s *Server) searchRegex(re *regexp.Regexp, allows func(string) bool) ([]searchHit, error) {
+	matchLine := regexLineMatcher(re)
+	var candidates []searchHit
+	hits, err := s.searchNotes(func() []searchHit {
+		var hits []searchHit
+		for _, doc := range s.search.docs {
+			if allows("task") {
+				for _, task := range doc.Tasks {
+					if len(matchLine(task.Text)) > 0 {
+						hits = append(hits, searchHit{Path: doc.Path, Type: "task", Task: &task})
+					}
+				}
+			}
+			if allows(doc.Type) {
+				candidates = append(candidates, searchHit{Path: doc.Path, Type: doc.Type})
+			}
+		}
+		return hits
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range candidates {
+		if len(matchLine(noteTitle(hit.Path))) > 0 {
+			hit.Score += searchNameBoost
+		}
+		data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(hit.Path)))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			hit.Score += float64(len(matchLine(strings.TrimSuffix(line, "\r"))))
+		}
+		if hit.Score > 0 {
+			hits = append(hits, hit)
+		}
+	}
+	sortSearchHits(hits)
+	return hits, nil
+}
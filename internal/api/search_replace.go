@@ -0,0 +1,285 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type ReplacePayload struct {
+	Pattern       string            `json:"pattern"`
+	Replacement   string            `json:"replacement"`
+	Mode          string            `json:"mode"`
+	CaseSensitive bool              `json:"caseSensitive"`
+	Folder        string            `json:"folder"`
+	Tag           string            `json:"tag"`
+	DryRun        bool              `json:"dryRun"`
+	BaseHashes    map[string]string `json:"baseHashes"`
+}
+
+type ReplaceResponse struct {
+	DryRun       bool          `json:"dryRun"`
+	Files        []ReplaceFile `json:"files"`
+	Replacements int           `json:"replacements"`
+}
+
+// ReplaceFile summarizes the replacements in one note. Hash is the note's
+// current hash: before the edit for a dry run, after it otherwise. Tag renames
+// also report the frontmatter tags before and after in Frontmatter.
+type ReplaceFile struct {
+	Path         string          `json:"path"`
+	Hash         string          `json:"hash"`
+	Replacements int             `json:"replacements"`
+	Changes      []ReplaceChange `json:"changes,omitempty"`
+	Frontmatter  *ReplaceChange  `json:"frontmatter,omitempty"`
+}
+
+type ReplaceChange struct {
+	LineNumber int    `json:"lineNumber"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+}
+
+type ReplaceConflictResponse struct {
+	Error string   `json:"error"`
+	Paths []string `json:"paths"`
+}
+
+// errEmptyMatch rejects patterns that match empty text somewhere, such as \b
+// or (?m)$, which would insert the replacement between characters.
+var errEmptyMatch = errors.New("pattern must not match empty text")
+
+// replaceEdit is the planned new content of one note.
+type replaceEdit struct {
+	file     ReplaceFile
+	original []byte
+	content  string
+}
+
+func (s *Server) handleSearchReplace(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[ReplacePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if payload.Pattern == "" {
+		writeError(w, http.StatusBadRequest, "pattern is required")
+		return
+	}
+
+	var re *regexp.Regexp
+	switch payload.Mode {
+	case "", "literal":
+		re, err = compileSearchRegex(regexp.QuoteMeta(payload.Pattern), payload.CaseSensitive)
+	case "regex":
+		re, err = compileSearchRegex(payload.Pattern, payload.CaseSensitive)
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be literal or regex")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if re.MatchString("") {
+		writeError(w, http.StatusBadRequest, errEmptyMatch.Error())
+		return
+	}
+	replace := func(line string) string { return re.ReplaceAllLiteralString(line, payload.Replacement) }
+	if payload.Mode == "regex" {
+		replace = func(line string) string { return re.ReplaceAllString(line, payload.Replacement) }
+	}
+
+	folder := ""
+	if raw := strings.TrimSpace(payload.Folder); raw != "" {
+		absFolder, relFolder, err := s.resolvePath(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		info, err := os.Stat(absFolder)
+		if err != nil {
+			if os.IsNotExist(err) {
+				writeError(w, http.StatusNotFound, "folder not found")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "unable to read folder")
+			return
+		}
+		if !info.IsDir() {
+			writeError(w, http.StatusBadRequest, "path is not a folder")
+			return
+		}
+		folder = relFolder
+	}
+	tag := strings.TrimPrefix(strings.TrimSpace(payload.Tag), "#")
+
+	var edits []replaceEdit
+	err = s.walkNotes(func(rel string, data []byte) error {
+		if folder != "" && !strings.HasPrefix(rel, folder+"/") {
+			return nil
+		}
+		content := string(data)
+		if tag != "" && !tagsContainFold(extractNoteTags(content), tag) {
+			return nil
+		}
+		edit, ok, err := planReplace(content, re, replace)
+		if err != nil || !ok {
+			return err
+		}
+		edit.file.Path = rel
+		edit.file.Hash = noteHash(data)
+		edit.original = data
+		edits = append(edits, edit)
+		return nil
+	})
+	if errors.Is(err, errEmptyMatch) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to scan notes")
+		return
+	}
+
+	resp := ReplaceResponse{DryRun: payload.DryRun, Files: make([]ReplaceFile, 0, len(edits))}
+	for _, edit := range edits {
+		resp.Replacements += edit.file.Replacements
+	}
+	if payload.DryRun {
+		for _, edit := range edits {
+			resp.Files = append(resp.Files, edit.file)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if payload.BaseHashes != nil {
+		if changed := replaceConflicts(edits, payload.BaseHashes); len(changed) > 0 {
+			s.logger.Warn("search replace conflict", "paths", changed)
+			writeJSON(w, http.StatusConflict, ReplaceConflictResponse{Error: "notes changed since the preview", Paths: changed})
+			return
+		}
+	}
+
+	changed, err := s.applyReplaceEdits(edits, "replace")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to update notes")
+		return
+	}
+	if len(changed) > 0 {
+		s.logger.Warn("search replace conflict", "paths", changed)
+		writeJSON(w, http.StatusConflict, ReplaceConflictResponse{Error: "notes changed during the replace", Paths: changed})
+		return
+	}
+	for _, edit := range edits {
+		edit.file.Hash = noteHash([]byte(edit.content))
+		edit.file.Changes = nil
+		resp.Files = append(resp.Files, edit.file)
+	}
+	s.logger.Info("search replace applied", "files", len(edits), "replacements", resp.Replacements)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// planReplace applies replace to each line of content that re matches. Lines
+// are handled one at a time, so a pattern never spans a line break. It
+// returns errEmptyMatch when re matches empty text in any line.
+func planReplace(content string, re *regexp.Regexp, replace func(string) string) (replaceEdit, bool, error) {
+	var edit replaceEdit
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		raw := strings.TrimSuffix(line, "\r")
+		locs := re.FindAllStringIndex(raw, -1)
+		for _, loc := range locs {
+			if loc[0] == loc[1] {
+				return edit, false, errEmptyMatch
+			}
+		}
+		count := len(locs)
+		if count == 0 {
+			continue
+		}
+		updated := replace(raw)
+		if updated == raw {
+			continue
+		}
+		edit.file.Replacements += count
+		edit.file.Changes = append(edit.file.Changes, ReplaceChange{LineNumber: i + 1, Before: raw, After: updated})
+		lines[i] = updated + line[len(raw):]
+	}
+	if len(edit.file.Changes) == 0 {
+		return edit, false, nil
+	}
+	edit.content = strings.Join(lines, "\n")
+	return edit, true, nil
+}
+
+// replaceConflicts lists the planned notes whose hash differs from the one
+// the client previewed, including notes that were not in the preview at all.
+func replaceConflicts(edits []replaceEdit, baseHashes map[string]string) []string {
+	var changed []string
+	for _, edit := range edits {
+		if baseHashes[edit.file.Path] != edit.file.Hash {
+			changed = append(changed, edit.file.Path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// applyReplaceEdits writes every edit or none: when a write fails, the notes
+// already written are put back to their original content. Every note stays
+// locked until all are written, and when one no longer has its planned
+// original content nothing is written and the changed paths are returned.
+// Reason labels the history revisions.
+func (s *Server) applyReplaceEdits(edits []replaceEdit, reason string) ([]string, error) {
+	paths := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		paths = append(paths, edit.file.Path)
+	}
+	// A fixed order keeps two bulk edits from each waiting on a note the
+	// other holds.
+	sort.Strings(paths)
+	for _, relPath := range paths {
+		defer s.lockNote(relPath)()
+	}
+
+	var changed []string
+	for _, edit := range edits {
+		current, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(edit.file.Path)))
+		if err != nil || noteHash(current) != noteHash(edit.original) {
+			changed = append(changed, edit.file.Path)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return changed, nil
+	}
+
+	for i, edit := range edits {
+		absPath := filepath.Join(s.notesDir, filepath.FromSlash(edit.file.Path))
+		s.snapshotNote(edit.file.Path, edit.original, reason)
+		if err := writeFileAtomic(absPath, []byte(edit.content), 0o644); err != nil {
+			s.logger.Error("unable to apply replacement", "path", edit.file.Path, "error", err)
+			s.rollbackReplaceEdits(edits[:i])
+			return nil, err
+		}
+	}
+	for _, edit := range edits {
+		s.indexPath(edit.file.Path)
+	}
+	return nil, nil
+}
+
+func (s *Server) rollbackReplaceEdits(edits []replaceEdit) {
+	for _, edit := range edits {
+		absPath := filepath.Join(s.notesDir, filepath.FromSlash(edit.file.Path))
+		if err := writeFileAtomic(absPath, edit.original, 0o644); err != nil {
+			s.logger.Error("unable to roll back replacement", "path", edit.file.Path, "error", err)
+		}
+	}
+}
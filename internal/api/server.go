@@ -7,16 +7,25 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Server struct {
 	notesDir string
 	logger   *slog.Logger
+	search   *searchIndex
+	recent   *recentNotes
+
+	// savedSearchesMu guards the load, change, and save of searches.json.
+	savedSearchesMu sync.Mutex
+	// noteLocks guards the read, check, and write of each note.
+	noteLocks noteLocks
 }
 
 var timeNow = time.Now
@@ -35,36 +44,39 @@ type TreeNode struct {
 }
 
 type NoteResponse struct {
-	Path     string    `json:"path"`
-	Content  string    `json:"content"`
-	Modified time.Time `json:"modified"`
+	Path             string         `json:"path"`
+	Content          string         `json:"content"`
+	Hash             string         `json:"hash"`
+	Modified         time.Time      `json:"modified"`
+	Frontmatter      map[string]any `json:"frontmatter,omitempty"`
+	FrontmatterError string         `json:"frontmatterError,omitempty"`
 }
 
 type NotePayload struct {
-	Path    string `json:"path"`
-	Content string `json:"content"`
+	Path     string `json:"path"`
+	Content  string `json:"content"`
+	BaseHash string `json:"baseHash,omitempty"`
 }
 
 type NoteRenamePayload struct {
-	Path    string `json:"path"`
-	NewPath string `json:"newPath"`
+	Path        string `json:"path"`
+	NewPath     string `json:"newPath"`
+	UpdateLinks bool   `json:"updateLinks,omitempty"`
 }
 
 type FolderPayload struct {
-	Path    string `json:"path"`
-	NewPath string `json:"newPath"`
+	Path        string `json:"path"`
+	NewPath     string `json:"newPath"`
+	UpdateLinks bool   `json:"updateLinks,omitempty"`
 }
 
 type SearchResult struct {
-	Path string `json:"path"`
-	Name string `json:"name"`
-	Type string `json:"type,omitempty"`
-	ID   string `json:"id,omitempty"`
-}
-
-type TagGroup struct {
-	Tag   string         `json:"tag"`
-	Notes []SearchResult `json:"notes"`
+	Path       string        `json:"path"`
+	Name       string        `json:"name"`
+	Type       string        `json:"type,omitempty"`
+	ID         string        `json:"id,omitempty"`
+	MatchCount int           `json:"matchCount,omitempty"`
+	Matches    []SearchMatch `json:"matches,omitempty"`
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -81,6 +93,10 @@ func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to load settings")
 		return
 	}
+	if id := strings.TrimSpace(r.URL.Query().Get("search")); id != "" {
+		s.handleSavedSearchTree(w, id)
+		return
+	}
 	pathParam := r.URL.Query().Get("path")
 	absPath, relPath, err := s.resolvePath(pathParam)
 	if err != nil {
@@ -113,6 +129,13 @@ func (s *Server) handleTree(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to build tree")
 		return
 	}
+	if relPath == "" {
+		searches, err := s.savedSearchNodes()
+		if err != nil {
+			s.logger.Warn("unable to load saved searches", "error", err)
+		}
+		children = append(children, searches...)
+	}
 	root.Children = children
 
 	writeJSON(w, http.StatusOK, root)
@@ -158,8 +181,18 @@ func (s *Server) handleGetNote(w http.ResponseWriter, r *http.Request) {
 	resp := NoteResponse{
 		Path:     relPath,
 		Content:  string(data),
+		Hash:     noteHash(data),
 		Modified: info.ModTime(),
 	}
+	if isMarkdown(absPath) {
+		s.recent.touch(relPath)
+		frontmatter, err := parseFrontmatter(resp.Content)
+		if err != nil {
+			resp.FrontmatterError = err.Error()
+		}
+		resp.Frontmatter = frontmatter
+	}
+	w.Header().Set("ETag", formatETag(resp.Hash))
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -186,6 +219,7 @@ func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	defer s.lockNote(relPath)()
 	if _, err := os.Stat(absPath); err == nil {
 		writeError(w, http.StatusConflict, "note already exists")
 		return
@@ -209,12 +243,13 @@ func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
 		content = applyTemplatePlaceholders(string(templateContent), timeNow(), templateContext(relPath))
 	}
 
-	if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
+	if err := writeFileAtomic(absPath, []byte(content), 0o644); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to create note")
 		return
 	}
 
 	// Task parsing is done on demand from note contents.
+	s.indexPath(relPath)
 
 	s.logger.Info("note created", "path", relPath, "bytes", len(content), "template", ok)
 	writeJSON(w, http.StatusCreated, map[string]string{"path": relPath})
@@ -237,6 +272,9 @@ func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The hash check and the write must not interleave with another writer,
+	// or two edits from the same base would both pass and one would be lost.
+	defer s.lockNote(relPath)()
 	info, err := os.Stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -255,16 +293,59 @@ func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.WriteFile(absPath, []byte(payload.Content), 0o644); err != nil {
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return
+	}
+
+	expected := expectedNoteHash(r.Header.Get("If-Match"), payload.BaseHash)
+	if expected != "" && expected != "*" {
+		currentHash := noteHash(current)
+		if currentHash != expected {
+			s.logger.Warn("note update conflict", "path", relPath, "baseHash", expected, "serverHash", currentHash)
+			w.Header().Set("ETag", formatETag(currentHash))
+			writeJSON(w, http.StatusConflict, NoteConflictResponse{
+				Error:         "note changed on the server",
+				Path:          relPath,
+				BaseHash:      expected,
+				ServerHash:    currentHash,
+				ServerContent: string(current),
+				Modified:      info.ModTime(),
+				Diff:          noteDiffHint(s.findRevision(relPath, expected), string(current), payload.Content),
+			})
+			return
+		}
+	}
+
+	content := payload.Content
+	rewritten := 0
+	if !isTemplate(relPath) && s.rewritesRelativeDates() {
+		content, rewritten = resolveRelativeDueDates(content, dueDateBase(relPath))
+	}
+
+	if string(current) != content {
+		s.snapshotNote(relPath, current, "update")
+	}
+
+	if err := writeFileAtomic(absPath, []byte(content), 0o644); err != nil {
 		s.logger.Error("unable to update note", "path", relPath, "absPath", absPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "unable to update note")
 		return
 	}
 
 	// Task parsing is done on demand from note contents.
+	s.indexPath(relPath)
 
-	s.logger.Info("note updated", "path", relPath, "bytes", len(payload.Content))
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath})
+	hash := noteHash([]byte(content))
+	s.logger.Info("note updated", "path", relPath, "bytes", len(content), "resolvedDueDates", rewritten)
+	w.Header().Set("ETag", formatETag(hash))
+	resp := map[string]string{"path": relPath, "hash": hash}
+	if rewritten > 0 {
+		// The client's copy still has the relative dates.
+		resp["content"] = content
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
@@ -298,13 +379,16 @@ func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.Remove(absPath); err != nil {
+	item, err := s.moveToTrash(absPath, relPath, "note")
+	if err != nil {
+		s.logger.Error("unable to move note to trash", "path", relPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "unable to delete note")
 		return
 	}
+	s.unindexPath(relPath)
 
-	s.logger.Info("note deleted", "path", relPath)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	s.logger.Info("note deleted", "path", relPath, "trashId", item.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "trashId": item.ID})
 }
 
 func (s *Server) handleGetFile(w http.ResponseWriter, r *http.Request) {
@@ -378,141 +462,113 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lowerQuery := strings.ToLower(query)
-	var results []SearchResult
-
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
+	caseSensitive := false
+	if raw := strings.TrimSpace(r.URL.Query().Get("caseSensitive")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
 		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if isIgnoredFile(d.Name()) {
-			return nil
-		}
-		if !isMarkdown(d.Name()) {
-			return nil
-		}
-
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
+			writeError(w, http.StatusBadRequest, "caseSensitive must be true or false")
+			return
 		}
-		rel = filepath.ToSlash(rel)
-		nameLower := strings.ToLower(d.Name())
-		if strings.Contains(nameLower, lowerQuery) {
-			results = append(results, SearchResult{
-				Path: rel,
-				Name: d.Name(),
-				Type: "note",
-			})
-			return nil
+		caseSensitive = parsed
+	}
+	types, err := parseSearchTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
 		}
+		limit = parsed
+	}
 
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		if strings.Contains(strings.ToLower(string(data)), lowerQuery) {
-			results = append(results, SearchResult{
-				Path: rel,
-				Name: d.Name(),
-				Type: "note",
-			})
-		}
+	run, matchLine, err := s.prepareSearch(query, strings.TrimSpace(r.URL.Query().Get("mode")), caseSensitive, types)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-		return nil
-	})
+	hits, err := run()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to search notes")
 		return
 	}
-
-	// Task search is intentionally disabled for now.
-
-	writeJSON(w, http.StatusOK, results)
-}
-
-func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
-	tagPattern := regexp.MustCompile(`(^|\s)#([A-Za-z]+)\b`)
-	tagMap := make(map[string]map[string]string)
-
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if hit.Task != nil {
+			results = append(results, taskSearchResult(hit, matchLine))
+			continue
 		}
-		if isIgnoredFile(d.Name()) {
-			return nil
+		result := SearchResult{
+			Path: hit.Path,
+			Name: filepath.Base(hit.Path),
+			Type: hit.Type,
 		}
-		if !isMarkdown(d.Name()) {
-			return nil
+		if data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(hit.Path))); err == nil {
+			result.Matches, result.MatchCount = findSearchMatches(string(data), matchLine)
 		}
+		results = append(results, result)
+	}
 
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
-		}
-		rel = filepath.ToSlash(rel)
+	writeJSON(w, http.StatusOK, results)
+}
 
-		data, err := os.ReadFile(path)
+// prepareSearch parses query for mode and returns the function that runs the
+// search and the line matcher used for snippets. Errors describe a bad query
+// or option.
+func (s *Server) prepareSearch(query, mode string, caseSensitive bool, types map[string]bool) (func() ([]searchHit, error), func(string) [][2]int, error) {
+	switch mode {
+	case "", "words":
+		parsed, err := parseSearchQuery(query)
 		if err != nil {
-			return nil
-		}
-		matches := tagPattern.FindAllStringSubmatch(string(data), -1)
-		if len(matches) == 0 {
-			return nil
+			return nil, nil, err
 		}
-
-		baseName := filepath.Base(rel)
-		for _, match := range matches {
-			tag := match[2]
-			if tag == "" {
-				continue
-			}
-			if tagMap[tag] == nil {
-				tagMap[tag] = make(map[string]string)
-			}
-			tagMap[tag][rel] = baseName
+		parsed.caseSensitive = caseSensitive
+		parsed.types = types
+		run := func() ([]searchHit, error) { return s.evaluateSearch(parsed) }
+		return run, parsed.lineMatcher(), nil
+	case "regex":
+		re, err := compileSearchRegex(query, caseSensitive)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		return nil
-	})
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to list tags")
-		return
+		filter := &searchQuery{types: types}
+		run := func() ([]searchHit, error) { return s.searchRegex(re, filter.allows) }
+		return run, regexLineMatcher(re), nil
 	}
+	return nil, nil, errors.New("mode must be words or regex")
+}
 
-	tags := make([]string, 0, len(tagMap))
-	for tag := range tagMap {
+// extractNoteTags returns the distinct tags in a note, frontmatter tags
+// first and then inline tags, in order of first appearance and with their
+// original case.
+func extractNoteTags(content string) []string {
+	frontmatter, _ := parseFrontmatter(content)
+	fmTags := frontmatterList(frontmatter, "tags")
+	inline := findTags(content)
+	seen := make(map[string]struct{}, len(fmTags)+len(inline))
+	tags := make([]string, 0, len(fmTags)+len(inline))
+	add := func(tag string) {
+		if _, ok := seen[tag]; ok || tag == "" {
+			return
+		}
+		seen[tag] = struct{}{}
 		tags = append(tags, tag)
 	}
-	sort.Slice(tags, func(i, j int) bool {
-		return strings.ToLower(tags[i]) < strings.ToLower(tags[j])
-	})
-
-	groups := make([]TagGroup, 0, len(tags))
-	for _, tag := range tags {
-		notesMap := tagMap[tag]
-		notes := make([]SearchResult, 0, len(notesMap))
-		for path, name := range notesMap {
-			notes = append(notes, SearchResult{Path: path, Name: name})
-		}
-		sort.Slice(notes, func(i, j int) bool {
-			nameA := strings.ToLower(notes[i].Name)
-			nameB := strings.ToLower(notes[j].Name)
-			if nameA == nameB {
-				return notes[i].Path < notes[j].Path
-			}
-			return nameA < nameB
-		})
-		groups = append(groups, TagGroup{Tag: tag, Notes: notes})
+	for _, tag := range fmTags {
+		add(tag)
 	}
-
-	writeJSON(w, http.StatusOK, groups)
+	for _, tag := range inline {
+		add(tag)
+	}
+	return tags
 }
 
 func (s *Server) handleRenameNote(w http.ResponseWriter, r *http.Request) {
@@ -575,13 +631,26 @@ func (s *Server) handleRenameNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var rewrites []linkRewrite
+	if payload.UpdateLinks {
+		rewrites, err = s.planLinkRewrites(map[string]string{relPath: relNewPath})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to scan links")
+			return
+		}
+	}
+
 	if err := os.Rename(absPath, absNewPath); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to rename note")
 		return
 	}
+	s.moveHistory(relPath, relNewPath)
+	s.unindexPath(relPath)
+	s.indexPath(relNewPath)
+	updated, skipped := s.applyLinkRewrites(rewrites)
 
-	s.logger.Info("note renamed", "path", relPath, "newPath", relNewPath)
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "newPath": relNewPath})
+	s.logger.Info("note renamed", "path", relPath, "newPath", relNewPath, "linksUpdated", len(updated), "linksSkipped", len(skipped))
+	writeJSON(w, http.StatusOK, RenameResponse{Path: relPath, NewPath: relNewPath, Updated: updated, Skipped: skipped})
 }
 
 func (s *Server) handleRenameFolder(w http.ResponseWriter, r *http.Request) {
@@ -633,13 +702,31 @@ func (s *Server) handleRenameFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var rewrites []linkRewrite
+	if payload.UpdateLinks {
+		moved, err := s.movedFiles(absPath, relPath, relNewPath)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to read folder")
+			return
+		}
+		rewrites, err = s.planLinkRewrites(moved)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to scan links")
+			return
+		}
+	}
+
 	if err := os.Rename(absPath, absNewPath); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to rename folder")
 		return
 	}
+	s.moveHistory(relPath, relNewPath)
+	s.unindexPath(relPath)
+	s.indexPath(relNewPath)
+	updated, skipped := s.applyLinkRewrites(rewrites)
 
-	s.logger.Info("folder renamed", "path", relPath, "newPath", relNewPath)
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "newPath": relNewPath})
+	s.logger.Info("folder renamed", "path", relPath, "newPath", relNewPath, "linksUpdated", len(updated), "linksSkipped", len(skipped))
+	writeJSON(w, http.StatusOK, RenameResponse{Path: relPath, NewPath: relNewPath, Updated: updated, Skipped: skipped})
 }
 
 func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
@@ -669,13 +756,21 @@ func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.RemoveAll(absPath); err != nil {
+	if relPath == "" {
+		writeError(w, http.StatusBadRequest, "root folder cannot be deleted")
+		return
+	}
+
+	item, err := s.moveToTrash(absPath, relPath, "folder")
+	if err != nil {
+		s.logger.Error("unable to move folder to trash", "path", relPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "unable to delete folder")
 		return
 	}
+	s.unindexPath(relPath)
 
-	s.logger.Info("folder deleted", "path", relPath)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	s.logger.Info("folder deleted", "path", relPath, "trashId", item.ID)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted", "trashId": item.ID})
 }
 
 func (s *Server) buildTree(absPath, relPath string, showTemplates bool) ([]TreeNode, error) {
@@ -691,6 +786,9 @@ func (s *Server) buildTree(absPath, relPath string, showTemplates bool) ([]TreeN
 		childAbs := filepath.Join(absPath, name)
 
 		if entry.IsDir() {
+			if s.isReservedDir(childAbs) {
+				continue
+			}
 			children, err := s.buildTree(childAbs, childRel, showTemplates)
 			if err != nil {
 				return nil, err
@@ -782,10 +880,82 @@ func (s *Server) resolvePath(input string) (string, string, error) {
 	if relCheck == ".." || strings.HasPrefix(relCheck, ".."+string(os.PathSeparator)) {
 		return "", "", errors.New("path escapes notes directory")
 	}
+	if isReservedName(strings.SplitN(clean, string(os.PathSeparator), 2)[0]) {
+		return "", "", errors.New("path is reserved")
+	}
 
 	return absPath, filepath.ToSlash(clean), nil
 }
 
+// isReservedDir reports whether absPath is one of the app-managed folders at
+// the root of the notes directory, which are hidden from the tree and skipped
+// by every walker.
+func (s *Server) isReservedDir(absPath string) bool {
+	return filepath.Dir(absPath) == filepath.Clean(s.notesDir) && isReservedName(filepath.Base(absPath))
+}
+
+// isReservedName compares without case, since on case-insensitive
+// filesystems .TRASH names the same folder as .trash.
+func isReservedName(name string) bool {
+	return strings.EqualFold(name, trashDirName) || strings.EqualFold(name, historyDirName) || strings.EqualFold(name, indexDirName)
+}
+
+// warnReservedDirs logs each app-managed folder at the notes root that holds
+// files the app did not write, such as a user's own .trash folder from before
+// the app used it. Those files are hidden from the tree, search, tasks, and
+// tags, and the API cannot open them.
+func (s *Server) warnReservedDirs() {
+	for _, name := range []string{trashDirName, historyDirName, indexDirName} {
+		dir := filepath.Join(s.notesDir, name)
+		foreign := 0
+		err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			if !isAppFile(name, filepath.ToSlash(rel)) {
+				foreign++
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("unable to check reserved folder", "folder", name, "error", err)
+			continue
+		}
+		if foreign > 0 {
+			s.logger.Warn("reserved folder holds files the app did not create; they are hidden and cannot be opened", "folder", name, "files", foreign)
+		}
+	}
+}
+
+// isAppFile reports whether rel, relative to the reserved folder name, has the
+// layout the app writes there: trash entries and history revisions named by
+// timestamp, and the search index file.
+func isAppFile(name, rel string) bool {
+	switch name {
+	case trashDirName:
+		entry, _, _ := strings.Cut(rel, "/")
+		stamp, _, _ := strings.Cut(strings.TrimSuffix(entry, ".json"), "-")
+		return isTimestampID(stamp)
+	case historyDirName:
+		stamp, _, _ := strings.Cut(strings.TrimSuffix(path.Base(rel), ".md"), "_")
+		return isTimestampID(stamp)
+	default:
+		return rel == searchIndexFile
+	}
+}
+
+func isTimestampID(stamp string) bool {
+	_, err := time.ParseInLocation(historyIDLayout, stamp, time.UTC)
+	return err == nil
+}
+
 func (s *Server) ensureDailyNote() error {
 	settings, _, err := s.loadSettings()
 	if err != nil {
@@ -814,6 +984,8 @@ func (s *Server) ensureDailyNote() error {
 
 	today := timeNow().Format("2006-01-02")
 	notePath := filepath.Join(dailyDir, today+".md")
+	relPath := filepath.ToSlash(filepath.Join(cleaned, today+".md"))
+	defer s.lockNote(relPath)()
 	if _, err := os.Stat(notePath); err == nil {
 		return nil
 	} else if !os.IsNotExist(err) {
@@ -829,10 +1001,9 @@ func (s *Server) ensureDailyNote() error {
 	}
 	finalContent := string(content)
 	if ok {
-		relPath := filepath.ToSlash(filepath.Join(cleaned, today+".md"))
 		finalContent = applyTemplatePlaceholders(finalContent, timeNow(), templateContext(relPath))
 	}
-	return os.WriteFile(notePath, []byte(finalContent), 0o644)
+	return writeFileAtomic(notePath, []byte(finalContent), 0o644)
 }
 
 func (s *Server) folderTemplateContent(dir string) ([]byte, bool, error) {
@@ -3,11 +3,17 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -15,7 +21,9 @@ import (
 func setupTestRouter(t *testing.T) (string, http.Handler) {
 	t.Helper()
 	dir := t.TempDir()
-	return dir, NewRouter(dir)
+	s := New(dir)
+	t.Cleanup(s.Close)
+	return dir, s.Routes()
 }
 
 func writeFile(t *testing.T, path, content string) {
@@ -382,7 +390,7 @@ func TestTasksFromNotes(t *testing.T) {
 		"Intro",
 		"- [ ] Call Mom +Home #Family @Alice >2025-01-31 ^2",
 		"  - [x] Done thing +Work >2025-02-01 ^5",
-		"- [ ] Bad due date >tomorrow",
+		"- [ ] Bad due date >someday",
 	}, "\n")
 	writeFile(t, filepath.Join(dir, "tasks-note.md"), content)
 
@@ -669,8 +677,8 @@ func TestTagsEndpoint(t *testing.T) {
 	for _, group := range groups {
 		groupMap[group.Tag] = group
 	}
-	if len(groupMap) != 3 {
-		t.Fatalf("expected 3 tags, got %d", len(groupMap))
+	if len(groupMap) != 2 {
+		t.Fatalf("expected 2 tags, got %d", len(groupMap))
 	}
 	tagOne, ok := groupMap["TagOne"]
 	if !ok || len(tagOne.Notes) != 1 || tagOne.Notes[0].Path != "alpha.md" {
@@ -687,8 +695,8 @@ func TestTagsEndpoint(t *testing.T) {
 	if !paths["alpha.md"] || !paths[filepath.ToSlash(filepath.Join("sub", "beta.md"))] {
 		t.Fatalf("expected TagTwo in alpha.md and sub/beta.md")
 	}
-	if _, ok := groupMap["tagtwo"]; !ok {
-		t.Fatalf("expected tagtwo tag")
+	if _, ok := groupMap["tagtwo"]; ok {
+		t.Fatalf("expected tagtwo to share the TagTwo group")
 	}
 }
 
@@ -704,3 +712,2503 @@ func TestFilesEndpoint(t *testing.T) {
 		t.Fatalf("expected file contents")
 	}
 }
+
+func TestUpdateNoteConflict(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "shared.md"), "line one\nline two\nline three")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes?path=shared.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	var note NoteResponse
+	decodeJSONBody(t, rec, &note)
+	if note.Hash == "" || etag != `"`+note.Hash+`"` {
+		t.Fatalf("expected ETag to match hash, got %q and %q", etag, note.Hash)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":     "shared.md",
+		"content":  "line one\nline two (tab a)\nline three",
+		"baseHash": note.Hash,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":     "shared.md",
+		"content":  "line one\nline two (tab b)\nline three",
+		"baseHash": note.Hash,
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	var conflict NoteConflictResponse
+	decodeJSONBody(t, rec, &conflict)
+	if conflict.ServerContent != "line one\nline two (tab a)\nline three" {
+		t.Fatalf("expected server content in conflict, got %q", conflict.ServerContent)
+	}
+	if conflict.Diff.StartLine != 2 || conflict.Diff.ServerEndLine != 2 || conflict.Diff.ClientEndLine != 2 {
+		t.Fatalf("expected diff hint on line 2, got %#v", conflict.Diff)
+	}
+	wantChange := NoteChangeRange{BaseStartLine: 2, BaseEndLine: 2, StartLine: 2, EndLine: 2}
+	if !conflict.Diff.Base || *conflict.Diff.Server != wantChange || *conflict.Diff.Client != wantChange || !conflict.Diff.Overlap {
+		t.Fatalf("expected both sides to change base line 2, got %#v", conflict.Diff)
+	}
+
+	writeFile(t, filepath.Join(dir, "list.md"), "a\nb\nc\nd")
+	listBase := noteHash([]byte("a\nb\nc\nd"))
+	doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "list.md", "content": "A\nb\nc\nd", "baseHash": listBase})
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "list.md", "content": "a\nb\nc\nD", "baseHash": listBase})
+	conflict = NoteConflictResponse{}
+	decodeJSONBody(t, rec, &conflict)
+	if rec.Code != http.StatusConflict || conflict.Diff.Server.BaseEndLine != 1 || conflict.Diff.Client.BaseStartLine != 4 || conflict.Diff.Overlap {
+		t.Fatalf("expected separate edits on lines 1 and 4, got %d %#v", rec.Code, conflict.Diff)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/notes", strings.NewReader(`{"path":"shared.md","content":"forced"}`))
+	req.Header.Set("If-Match", `"stale"`)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 for stale If-Match, got %d", rec.Code)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "shared.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if string(data) != "line one\nline two (tab a)\nline three" {
+		t.Fatalf("expected first save to be kept, got %q", string(data))
+	}
+}
+
+func TestConcurrentUpdatesFromOneBase(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "shared.md"), "start")
+	base := noteHash([]byte("start"))
+
+	const writers = 8
+	codes := make([]int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+				"path":     "shared.md",
+				"content":  "edit " + strconv.Itoa(i),
+				"baseHash": base,
+			})
+			codes[i] = rec.Code
+		}(i)
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			saved++
+		} else if code != http.StatusConflict {
+			t.Fatalf("expected status 200 or 409, got %d", code)
+		}
+	}
+	if saved != 1 {
+		t.Fatalf("expected exactly one save from the shared base, got %d", saved)
+	}
+}
+
+func TestUpdateNotePreservesModeAndLeavesNoTempFiles(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "private.md")
+	writeFile(t, notePath, "before")
+	if err := os.Chmod(notePath, 0o600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":    "private.md",
+		"content": "after",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	info, err := os.Stat(notePath)
+	if err != nil {
+		t.Fatalf("stat note: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600 to be preserved, got %v", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(notePath)
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if string(data) != "after" {
+		t.Fatalf("expected updated content, got %q", string(data))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Fatalf("expected no temp files, found %q", entry.Name())
+		}
+	}
+}
+
+func TestTrashKeepsNoteHistory(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "a.md"), "first")
+	history := func(path string) []Revision {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/notes/history?path="+path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var revisions []Revision
+		decodeJSONBody(t, rec, &revisions)
+		return revisions
+	}
+
+	doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "a.md", "content": "second"})
+	if rec := doRequest(t, router, http.MethodDelete, "/notes?path=a.md", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, http.MethodPost, "/notes", map[string]string{"path": "a.md", "content": "new"}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+	if revisions := history("a.md"); len(revisions) != 0 {
+		t.Fatalf("expected a recreated note to start without history, got %#v", revisions)
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/trash", nil)
+	var items []TrashItem
+	decodeJSONBody(t, rec, &items)
+	if rec := doRequest(t, router, http.MethodPost, "/trash/restore", map[string]string{"id": items[0].ID, "path": "b.md"}); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if revisions := history("b.md"); len(revisions) != 1 || revisions[0].Reason != "update" {
+		t.Fatalf("expected the restored note to keep its history, got %#v", revisions)
+	}
+
+	doRequest(t, router, http.MethodDelete, "/notes?path=b.md", nil)
+	if rec := doRequest(t, router, http.MethodDelete, "/trash", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, trashDirName))
+	if len(entries) != 0 {
+		t.Fatalf("expected emptying the trash to remove history too, got %v", entries)
+	}
+}
+
+func TestDeleteMovesToTrashAndRestore(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "keep.md"), "keep #Visible")
+	writeFile(t, filepath.Join(dir, "Projects", "plan.md"), "plan #Trashed\n- [ ] Trashed task")
+	writeFile(t, filepath.Join(dir, "gone.md"), "gone")
+
+	rec := doRequest(t, router, http.MethodDelete, "/notes?path=gone.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodDelete, "/folders?path=Projects", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Projects")); !os.IsNotExist(err) {
+		t.Fatalf("expected Projects to be removed from notes")
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tree", nil)
+	var tree TreeNode
+	decodeJSONBody(t, rec, &tree)
+	for _, child := range tree.Children {
+		if child.Name == trashDirName {
+			t.Fatalf("expected trash to be hidden from tree")
+		}
+	}
+	rec = doRequest(t, router, http.MethodGet, "/search?query=plan", nil)
+	var matches []SearchResult
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 0 {
+		t.Fatalf("expected trashed notes to be excluded from search, got %#v", matches)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/tags", nil)
+	var groups []TagGroup
+	decodeJSONBody(t, rec, &groups)
+	for _, group := range groups {
+		if group.Tag == "Trashed" {
+			t.Fatalf("expected trashed tags to be excluded")
+		}
+	}
+	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 0 {
+		t.Fatalf("expected trashed tasks to be excluded, got %#v", list.Tasks)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/trash", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var items []TrashItem
+	decodeJSONBody(t, rec, &items)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 trash items, got %d", len(items))
+	}
+	var folderItem TrashItem
+	for _, item := range items {
+		if item.OriginalPath == "Projects" {
+			folderItem = item
+		}
+	}
+	if folderItem.Type != "folder" {
+		t.Fatalf("expected Projects folder in trash, got %#v", items)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/trash/restore", map[string]string{"id": folderItem.ID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Projects", "plan.md"))
+	if err != nil {
+		t.Fatalf("read restored note: %v", err)
+	}
+	if !strings.Contains(string(data), "plan") {
+		t.Fatalf("expected restored content, got %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodDelete, "/trash", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var purge TrashPurgeResponse
+	decodeJSONBody(t, rec, &purge)
+	if purge.Removed != 1 {
+		t.Fatalf("expected 1 item purged, got %d", purge.Removed)
+	}
+
+	for _, reserved := range []string{".trash/anything.md", ".TRASH/anything.md", ".History/a.md", ".Index/search.json"} {
+		rec = doRequest(t, router, http.MethodGet, "/notes?path="+reserved, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected reserved path %s to be rejected, got %d", reserved, rec.Code)
+		}
+	}
+}
+
+func TestNoteHistoryDiffAndRestore(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "journal.md"), "one\ntwo\nthree")
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":    "journal.md",
+		"content": "one\n2\nthree",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/history?path=journal.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var revisions []Revision
+	decodeJSONBody(t, rec, &revisions)
+	if len(revisions) != 1 || revisions[0].Reason != "update" {
+		t.Fatalf("expected one update revision, got %#v", revisions)
+	}
+	id := revisions[0].ID
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/revision?path=journal.md&id="+id, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var revision RevisionResponse
+	decodeJSONBody(t, rec, &revision)
+	if revision.Content != "one\ntwo\nthree" {
+		t.Fatalf("expected original content, got %q", revision.Content)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/diff?path=journal.md&from="+id, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var diff RevisionDiffResponse
+	decodeJSONBody(t, rec, &diff)
+	if !strings.Contains(diff.Diff, "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n") {
+		t.Fatalf("unexpected diff %q", diff.Diff)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/notes/restore", map[string]string{
+		"path": "journal.md",
+		"id":   id,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "journal.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if string(data) != "one\ntwo\nthree" {
+		t.Fatalf("expected restored content, got %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/history?path=journal.md", nil)
+	revisions = nil
+	decodeJSONBody(t, rec, &revisions)
+	if len(revisions) != 2 || revisions[0].Reason != "restore" {
+		t.Fatalf("expected restore snapshot, got %#v", revisions)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "settings.json"), `{"version":2,"historyMaxRevisions":2}`)
+	writeFile(t, filepath.Join(dir, "busy.md"), "v0")
+
+	originalNow := timeNow
+	t.Cleanup(func() { timeNow = originalNow })
+	for i := 1; i <= 4; i++ {
+		stamp := time.Date(2025, 3, 1, 9, i, 0, 0, time.UTC)
+		timeNow = func() time.Time { return stamp }
+		rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+			"path":    "busy.md",
+			"content": "v" + string(rune('0'+i)),
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/notes/history?path=busy.md", nil)
+	var revisions []Revision
+	decodeJSONBody(t, rec, &revisions)
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 retained revisions, got %d", len(revisions))
+	}
+	rec = doRequest(t, router, http.MethodGet, "/notes/revision?path=busy.md&id="+revisions[0].ID, nil)
+	var newest RevisionResponse
+	decodeJSONBody(t, rec, &newest)
+	if newest.Content != "v3" {
+		t.Fatalf("expected newest revision v3, got %q", newest.Content)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	from := strings.Join([]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}, "\n")
+	to := strings.Join([]string{"a", "B", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}, "\n")
+	got := unifiedDiff("old", "new", from, to)
+	expected := "--- old\n+++ new\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -9,3 +9,4 @@\n i\n j\n k\n+l\n"
+	if got != expected {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+	if unifiedDiff("old", "new", from, from) != "" {
+		t.Fatalf("expected empty diff for identical input")
+	}
+}
+
+func TestLinksResolve(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Roadmap.md"), "roadmap")
+	writeFile(t, filepath.Join(dir, "Projects", "Index.md"), "projects index")
+	writeFile(t, filepath.Join(dir, "Areas", "Index.md"), "areas index")
+
+	cases := []struct {
+		query     string
+		status    string
+		path      string
+		heading   string
+		alias     string
+		ambiguous int
+	}{
+		{query: "target=roadmap", status: "resolved", path: "Projects/Roadmap.md"},
+		{query: "target=Projects/Roadmap%23Goals%7CPlan", status: "resolved", path: "Projects/Roadmap.md", heading: "Goals", alias: "Plan"},
+		{query: "target=%5B%5BAreas/Index%5D%5D", status: "resolved", path: "Areas/Index.md"},
+		{query: "target=Index", status: "ambiguous", ambiguous: 2},
+		{query: "target=Index&from=Areas/Other.md", status: "resolved", path: "Areas/Index.md"},
+		{query: "target=Missing", status: "missing"},
+	}
+	for _, tc := range cases {
+		rec := doRequest(t, router, http.MethodGet, "/links/resolve?"+tc.query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", tc.query, rec.Code)
+		}
+		var resolution LinkResolution
+		decodeJSONBody(t, rec, &resolution)
+		if resolution.Status != tc.status || resolution.Path != tc.path {
+			t.Fatalf("%s: unexpected resolution %#v", tc.query, resolution)
+		}
+		if resolution.Heading != tc.heading || resolution.Alias != tc.alias {
+			t.Fatalf("%s: unexpected heading/alias %#v", tc.query, resolution)
+		}
+		if len(resolution.Candidates) != tc.ambiguous {
+			t.Fatalf("%s: expected %d candidates, got %#v", tc.query, tc.ambiguous, resolution.Candidates)
+		}
+	}
+}
+
+func TestBacklinksEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Roadmap.md"), "# Roadmap")
+	writeFile(t, filepath.Join(dir, "Daily", "2025-01-01.md"), strings.Join([]string{
+		"Intro",
+		"Reviewed [[Roadmap|the plan]] today.",
+		"See also [roadmap](../Projects/Roadmap.md#goals).",
+		"```",
+		"[[Roadmap]] inside code",
+		"```",
+	}, "\n"))
+	writeFile(t, filepath.Join(dir, "Projects", "Other.md"), "[Roadmap](Roadmap.md) and [[Missing]]")
+	writeFile(t, filepath.Join(dir, "Unrelated.md"), "nothing here")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes/backlinks?path=Projects/Roadmap.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var backlinks []Backlink
+	decodeJSONBody(t, rec, &backlinks)
+	if len(backlinks) != 3 {
+		t.Fatalf("expected 3 backlinks, got %#v", backlinks)
+	}
+	first := backlinks[0]
+	if first.Path != "Daily/2025-01-01.md" || first.LineNumber != 2 || first.Kind != "wiki" {
+		t.Fatalf("unexpected first backlink %#v", first)
+	}
+	if first.Snippet != "Reviewed [[Roadmap|the plan]] today." {
+		t.Fatalf("unexpected snippet %q", first.Snippet)
+	}
+	if backlinks[1].LineNumber != 3 || backlinks[1].Kind != "markdown" {
+		t.Fatalf("unexpected markdown backlink %#v", backlinks[1])
+	}
+	if backlinks[2].Path != "Projects/Other.md" {
+		t.Fatalf("expected Projects/Other.md backlink, got %#v", backlinks[2])
+	}
+}
+
+func TestBacklinksSkipInlineCode(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "X.md"), "# X")
+	writeFile(t, filepath.Join(dir, "Docs.md"), "Write `[[X]]` for a wiki link or `[x](X.md)` for a markdown one.\n``code with `[[X]]` inside``")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes/backlinks?path=X.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var backlinks []Backlink
+	decodeJSONBody(t, rec, &backlinks)
+	if len(backlinks) != 0 {
+		t.Fatalf("expected no backlinks from inline code, got %#v", backlinks)
+	}
+}
+
+func TestRenameUpdatesLinks(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Roadmap.md"), "See [home](../Home.md) and ![chart](chart.png)")
+	writeFile(t, filepath.Join(dir, "Projects", "chart.png"), "png")
+	writeFile(t, filepath.Join(dir, "Home.md"), "")
+	writeFile(t, filepath.Join(dir, "Index.md"), strings.Join([]string{
+		"[[Roadmap#Goals|the plan]]",
+		"[map](Projects/Roadmap.md#goals) [map](Projects/Roadmap.md)",
+		"![chart](/files?path=Projects/chart.png)",
+		"[[Home]]",
+	}, "\n"))
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]any{
+		"path":        "Projects/Roadmap.md",
+		"newPath":     "Projects/Plan",
+		"updateLinks": true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp RenameResponse
+	decodeJSONBody(t, rec, &resp)
+	if len(resp.Updated) != 1 || resp.Updated[0] != "Index.md" {
+		t.Fatalf("expected Index.md to be updated, got %#v", resp.Updated)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "Index.md"))
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	expected := strings.Join([]string{
+		"[[Plan#Goals|the plan]]",
+		"[map](Projects/Plan.md#goals) [map](Projects/Plan.md)",
+		"![chart](/files?path=Projects/chart.png)",
+		"[[Home]]",
+	}, "\n")
+	if string(data) != expected {
+		t.Fatalf("unexpected index content %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/folders", map[string]any{
+		"path":        "Projects",
+		"newPath":     "Archive/Projects",
+		"updateLinks": true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	resp = RenameResponse{}
+	decodeJSONBody(t, rec, &resp)
+	if len(resp.Updated) != 2 {
+		t.Fatalf("expected 2 updated notes, got %#v", resp.Updated)
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "Index.md"))
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	expected = strings.Join([]string{
+		"[[Plan#Goals|the plan]]",
+		"[map](Archive/Projects/Plan.md#goals) [map](Archive/Projects/Plan.md)",
+		"![chart](/files?path=Archive/Projects/chart.png)",
+		"[[Home]]",
+	}, "\n")
+	if string(data) != expected {
+		t.Fatalf("unexpected index content after folder move %q", string(data))
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "Archive", "Projects", "Plan.md"))
+	if err != nil {
+		t.Fatalf("read moved note: %v", err)
+	}
+	if string(data) != "See [home](../../Home.md) and ![chart](chart.png)" {
+		t.Fatalf("unexpected moved note content %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]any{
+		"path":    "Home.md",
+		"newPath": "Start",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "Index.md"))
+	if err != nil {
+		t.Fatalf("read index: %v", err)
+	}
+	if !strings.Contains(string(data), "[[Home]]") {
+		t.Fatalf("expected links to stay untouched without updateLinks")
+	}
+}
+
+func TestRenameKeepsLinksInInlineCode(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Target.md"), "# Target")
+	notePath := filepath.Join(dir, "Docs.md")
+	writeFile(t, notePath, "Link with [[Target]], as in `[[Target]]` or `[x](Target.md)`")
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]any{
+		"path":        "Target.md",
+		"newPath":     "Renamed",
+		"updateLinks": true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(notePath)
+	if string(data) != "Link with [[Renamed]], as in `[[Target]]` or `[x](Target.md)`" {
+		t.Fatalf("expected links in inline code to stay as written, got %q", data)
+	}
+}
+
+func TestGraphEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Hub.md"), "[[Alpha]] [[Alpha]] [beta](Work/Beta.md) #Core")
+	writeFile(t, filepath.Join(dir, "Alpha.md"), "[[Gamma]] #core #Core")
+	writeFile(t, filepath.Join(dir, "Work", "Beta.md"), "#Work")
+	writeFile(t, filepath.Join(dir, "Gamma.md"), "leaf")
+	writeFile(t, filepath.Join(dir, "Lonely.md"), "alone, unlike `[[Hub]]` or `[hub](Hub.md)`")
+
+	rec := doRequest(t, router, http.MethodGet, "/graph", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var graph GraphResponse
+	decodeJSONBody(t, rec, &graph)
+	notes := 0
+	for _, node := range graph.Nodes {
+		if node.Type == "note" {
+			notes++
+		}
+	}
+	if notes != 5 || len(graph.Nodes) != 7 {
+		t.Fatalf("expected 5 notes and 2 tags, got %#v", graph.Nodes)
+	}
+	foundWeighted := false
+	for _, edge := range graph.Edges {
+		if edge.Type == "link" && edge.Source == "Hub.md" && edge.Target == "Alpha.md" {
+			foundWeighted = edge.Weight == 2
+		}
+	}
+	if !foundWeighted {
+		t.Fatalf("expected Hub -> Alpha edge with weight 2, got %#v", graph.Edges)
+	}
+	for _, edge := range graph.Edges {
+		if edge.Source == "Lonely.md" {
+			t.Fatalf("expected no edges from links in inline code, got %#v", edge)
+		}
+	}
+	tagEdges := 0
+	for _, edge := range graph.Edges {
+		if edge.Type == "tag" && edge.Target == "tag:"+tagKey("Core") {
+			tagEdges++
+		}
+	}
+	if tagEdges != 2 || graph.Nodes[5].ID != "tag:"+tagKey("Core") || graph.Nodes[5].Label != "#Core" {
+		t.Fatalf("expected #core and #Core to share one tag node, got %#v %#v", graph.Nodes, graph.Edges)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/graph?note=Gamma.md&depth=1", nil)
+	graph = GraphResponse{}
+	decodeJSONBody(t, rec, &graph)
+	ids := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		ids[node.ID] = true
+	}
+	if !ids["Gamma.md"] || !ids["Alpha.md"] || ids["Hub.md"] {
+		t.Fatalf("unexpected depth-1 neighborhood %#v", graph.Nodes)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/graph?tag=core", nil)
+	graph = GraphResponse{}
+	decodeJSONBody(t, rec, &graph)
+	for _, node := range graph.Nodes {
+		if node.Type == "note" && node.ID != "Hub.md" && node.ID != "Alpha.md" {
+			t.Fatalf("unexpected note %q in tag filter", node.ID)
+		}
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/graph?folder=Work", nil)
+	graph = GraphResponse{}
+	decodeJSONBody(t, rec, &graph)
+	if len(graph.Nodes) != 2 || graph.Nodes[0].ID != "Work/Beta.md" || graph.Nodes[0].Folder != "Work" {
+		t.Fatalf("unexpected folder graph %#v", graph.Nodes)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/graph?depth=9", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid depth, got %d", rec.Code)
+	}
+}
+
+func TestLinkReport(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Index.md"), strings.Join([]string{
+		"[[Roadmap]] [[Ghost]] [[Dup]]",
+		"[gone](Missing.md) ![pic](used.png) ![[embed.png]]",
+		"![lost](/files?path=lost.png) [site](https://example.com)",
+		"Write `[[Nowhere]]` or `[x](Gone.md)` to link a note.",
+	}, "\n"))
+	writeFile(t, filepath.Join(dir, "Roadmap.md"), "roadmap")
+	writeFile(t, filepath.Join(dir, "A", "Dup.md"), "")
+	writeFile(t, filepath.Join(dir, "B", "Dup.md"), "")
+	writeFile(t, filepath.Join(dir, "used.png"), "png")
+	writeFile(t, filepath.Join(dir, "img", "embed.png"), "png")
+	writeFile(t, filepath.Join(dir, "unused.pdf"), "pdf")
+
+	rec := doRequest(t, router, http.MethodGet, "/reports/links", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var report LinkReport
+	decodeJSONBody(t, rec, &report)
+
+	reasons := make(map[string]string)
+	for _, broken := range report.BrokenLinks {
+		reasons[broken.Target] = broken.Kind + ":" + broken.Reason
+	}
+	if reasons["Nowhere"] != "" || reasons["Gone.md"] != "" {
+		t.Fatalf("expected links in inline code not to be reported, got %#v", report.BrokenLinks)
+	}
+	if len(report.BrokenLinks) != 3 || reasons["Ghost"] != "wiki:missing" || reasons["Dup"] != "wiki:ambiguous" || reasons["Missing.md"] != "markdown:missing" {
+		t.Fatalf("unexpected broken links %#v", report.BrokenLinks)
+	}
+	if len(report.BrokenFileRefs) != 1 || report.BrokenFileRefs[0].LineNumber != 3 {
+		t.Fatalf("unexpected broken file refs %#v", report.BrokenFileRefs)
+	}
+	if strings.Join(report.OrphanNotes, ",") != "A/Dup.md,B/Dup.md" {
+		t.Fatalf("unexpected orphans %#v", report.OrphanNotes)
+	}
+	if strings.Join(report.UnusedAttachments, ",") != "unused.pdf" {
+		t.Fatalf("unexpected unused attachments %#v", report.UnusedAttachments)
+	}
+}
+
+func TestNoteFrontmatter(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Project.md"), strings.Join([]string{
+		"---",
+		"title: Project",
+		"# keep this comment",
+		"tags: [work, \"#planning\"]",
+		"aliases:",
+		"  - Big Plan",
+		"---",
+		"Body #inline",
+		"",
+	}, "\n"))
+	writeFile(t, filepath.Join(dir, "Broken.md"), "---\ntitle: [unclosed\n---\nbody")
+	writeFile(t, filepath.Join(dir, "Index.md"), "See [[big plan|the plan]]")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes?path=Project.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var note NoteResponse
+	decodeJSONBody(t, rec, &note)
+	if note.Frontmatter["title"] != "Project" || note.FrontmatterError != "" {
+		t.Fatalf("unexpected frontmatter %#v (%s)", note.Frontmatter, note.FrontmatterError)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes?path=Broken.md", nil)
+	note = NoteResponse{}
+	decodeJSONBody(t, rec, &note)
+	if rec.Code != http.StatusOK || note.FrontmatterError == "" || note.Frontmatter != nil {
+		t.Fatalf("expected frontmatter error, got %d %#v", rec.Code, note)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tags", nil)
+	var groups []TagGroup
+	decodeJSONBody(t, rec, &groups)
+	tags := make(map[string]int)
+	for _, group := range groups {
+		tags[group.Tag] = len(group.Notes)
+	}
+	if tags["work"] != 1 || tags["planning"] != 1 || tags["inline"] != 1 {
+		t.Fatalf("expected frontmatter and inline tags, got %#v", tags)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/links/resolve?target=big%20plan&from=Index.md", nil)
+	var resolution LinkResolution
+	decodeJSONBody(t, rec, &resolution)
+	if resolution.Status != "resolved" || resolution.Path != "Project.md" {
+		t.Fatalf("expected alias to resolve, got %#v", resolution)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes/frontmatter", map[string]any{
+		"path":   "Project.md",
+		"set":    map[string]any{"status": "active", "title": "Renamed"},
+		"remove": []string{"aliases"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated FrontmatterResponse
+	decodeJSONBody(t, rec, &updated)
+	if updated.Frontmatter["status"] != "active" || updated.Frontmatter["aliases"] != nil {
+		t.Fatalf("unexpected frontmatter %#v", updated.Frontmatter)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Project.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	expected := "---\ntitle: Renamed\n# keep this comment\ntags: [work, \"#planning\"]\nstatus: active\n---\nBody #inline\n"
+	if string(data) != expected {
+		t.Fatalf("unexpected note content %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes/frontmatter", map[string]any{
+		"path":     "Project.md",
+		"set":      map[string]any{"status": "done"},
+		"baseHash": "stale",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes/frontmatter", map[string]any{
+		"path": "Index.md",
+		"set":  map[string]any{"tags": []string{"home"}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Index.md"))
+	if string(data) != "---\ntags:\n  - home\n---\nSee [[big plan|the plan]]" {
+		t.Fatalf("unexpected note content %q", string(data))
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	t.Cleanup(func() { timeNow = originalNow })
+	now := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	writeFile(t, filepath.Join(dir, "Garden.md"), "Notes about planting")
+	writeFile(t, filepath.Join(dir, "Journal.md"), "Went to the garden, then the garden shop")
+	writeFile(t, filepath.Join(dir, "Other.md"), "nothing here")
+
+	search := func(query string) []string {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?query="+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var matches []SearchResult
+		decodeJSONBody(t, rec, &matches)
+		paths := make([]string, 0, len(matches))
+		for _, match := range matches {
+			paths = append(paths, match.Path)
+		}
+		return paths
+	}
+
+	if got := strings.Join(search("garden"), ","); got != "Garden.md,Journal.md" {
+		t.Fatalf("expected name match ranked first, got %s", got)
+	}
+	if got := strings.Join(search("gard+sho"), ","); got != "Journal.md" {
+		t.Fatalf("expected prefix match on every token, got %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexDirName, searchIndexFile)); err != nil {
+		t.Fatalf("expected persisted search index: %v", err)
+	}
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "Other.md", "content": "orchids"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := strings.Join(search("orchid"), ","); got != "Other.md" {
+		t.Fatalf("expected updated note to be indexed, got %s", got)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]string{"path": "Other.md", "newPath": "Flowers"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := strings.Join(search("orchids"), ","); got != "Flowers.md" {
+		t.Fatalf("expected renamed note to be indexed, got %s", got)
+	}
+	rec = doRequest(t, router, http.MethodDelete, "/notes?path=Flowers.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := search("orchids"); len(got) != 0 {
+		t.Fatalf("expected deleted note to leave the index, got %v", got)
+	}
+
+	writeFile(t, filepath.Join(dir, "sub", "External.md"), "edited outside the app")
+	if got := search("outside"); len(got) != 0 {
+		t.Fatalf("expected searches between reconciles to trust the index, got %v", got)
+	}
+	now = now.Add(searchReconcileInterval)
+	if got := strings.Join(search("outside"), ","); got != "sub/External.md" {
+		t.Fatalf("expected external edit to be indexed, got %s", got)
+	}
+
+	freshServer := New(dir)
+	t.Cleanup(freshServer.Close)
+	fresh := freshServer.Routes()
+	rec = doRequest(t, fresh, http.MethodGet, "/search?query=planting", nil)
+	var matches []SearchResult
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 1 || matches[0].Path != "Garden.md" {
+		t.Fatalf("expected persisted index to be usable, got %#v", matches)
+	}
+	rec = doRequest(t, fresh, http.MethodGet, "/tree", nil)
+	var tree TreeNode
+	decodeJSONBody(t, rec, &tree)
+	for _, child := range tree.Children {
+		if child.Name == indexDirName {
+			t.Fatalf("expected index folder to be hidden from tree")
+		}
+	}
+}
+
+func TestSearchFileNamesAndSubstrings(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "meeting-notes.md"), "agenda")
+	writeFile(t, filepath.Join(dir, "plans.md"), "The roadmap for Q3")
+
+	search := func(query string) []SearchResult {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var matches []SearchResult
+		decodeJSONBody(t, rec, &matches)
+		return matches
+	}
+
+	if matches := search("meeting-notes.md"); len(matches) != 1 || matches[0].Path != "meeting-notes.md" {
+		t.Fatalf("expected the full file name to match, got %#v", matches)
+	}
+	matches := search("map")
+	if len(matches) != 1 || matches[0].Path != "plans.md" {
+		t.Fatalf("expected a substring of roadmap to match, got %#v", matches)
+	}
+	if len(matches[0].Matches) != 1 || matches[0].Matches[0].Ranges[0] != (MatchRange{Start: 8, End: 11}) {
+		t.Fatalf("expected map highlighted inside roadmap, got %#v", matches[0].Matches)
+	}
+}
+
+func TestSearchMatchesAndSnippets(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	long := strings.Repeat("filler ", 20)
+	writeFile(t, filepath.Join(dir, "Trip.md"), strings.Join([]string{
+		"# Trip",
+		"  Pack the tent and the tents bag",
+		"nothing",
+		long + "Tent pegs " + long,
+		"Café tent",
+	}, "\n"))
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query=tent", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var results []SearchResult
+	decodeJSONBody(t, rec, &results)
+	if len(results) != 1 || results[0].MatchCount != 4 || len(results[0].Matches) != 3 {
+		t.Fatalf("unexpected results %#v", results)
+	}
+
+	first := results[0].Matches[0]
+	if first.LineNumber != 2 || first.Snippet != "Pack the tent and the tents bag" {
+		t.Fatalf("unexpected first match %#v", first)
+	}
+	if len(first.Ranges) != 2 || first.Ranges[0] != (MatchRange{Start: 9, End: 13}) || first.Ranges[1] != (MatchRange{Start: 22, End: 26}) {
+		t.Fatalf("unexpected ranges %#v", first.Ranges)
+	}
+
+	second := results[0].Matches[1]
+	if second.LineNumber != 4 || !strings.HasPrefix(second.Snippet, "…") || !strings.HasSuffix(second.Snippet, "…") {
+		t.Fatalf("expected trimmed snippet, got %#v", second)
+	}
+	chars := []rune(second.Snippet)
+	if got := string(chars[second.Ranges[0].Start:second.Ranges[0].End]); got != "Tent" {
+		t.Fatalf("expected range to cover Tent, got %q", got)
+	}
+
+	third := results[0].Matches[2]
+	if third.Ranges[0] != (MatchRange{Start: 5, End: 9}) {
+		t.Fatalf("expected character offsets after multibyte text, got %#v", third.Ranges)
+	}
+}
+
+func TestSearchQueryLanguage(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Alpha.md"), "---\ntags: [work]\n---\nThe exact phrase lives here")
+	writeFile(t, filepath.Join(dir, "Projects", "Beta.md"), "phrase exact but reversed #work #draft")
+	writeFile(t, filepath.Join(dir, "Home", "Gamma.md"), "exact phrase at home, apples")
+	writeFile(t, filepath.Join(dir, "Home", "Delta.md"), "oranges only")
+	writeFile(t, filepath.Join(dir, "Projects", "default.template"), "exact phrase template")
+
+	old := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"Projects/Alpha.md", "Home/Delta.md"} {
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+
+	search := func(query string) []string {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("query %q: expected status 200, got %d: %s", query, rec.Code, rec.Body.String())
+		}
+		var results []SearchResult
+		decodeJSONBody(t, rec, &results)
+		paths := make([]string, 0, len(results))
+		for _, result := range results {
+			paths = append(paths, result.Path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	cases := map[string]string{
+		`"exact phrase"`:                         "Home/Gamma.md,Projects/Alpha.md",
+		`"exact phrase" -apples`:                 "Projects/Alpha.md",
+		`exact phrase`:                           "Home/Gamma.md,Projects/Alpha.md,Projects/Beta.md",
+		`apples OR oranges`:                      "Home/Delta.md,Home/Gamma.md",
+		`tag:work`:                               "Projects/Alpha.md,Projects/Beta.md",
+		`tag:#work -tag:draft`:                   "Projects/Alpha.md",
+		`path:Projects/ exact`:                   "Projects/Alpha.md,Projects/Beta.md",
+		`in:home`:                                "Home/Delta.md,Home/Gamma.md",
+		`type:template exact`:                    "Projects/default.template",
+		`type:note OR type:template template`:    "Projects/default.template",
+		`modified:<2025-01-01`:                   "Home/Delta.md,Projects/Alpha.md",
+		`modified:2024-06-01`:                    "Home/Delta.md,Projects/Alpha.md",
+		`modified:2024-05-01..2024-05-31`:        "",
+		`modified:>2024-06-01 exact`:             "Home/Gamma.md,Projects/Beta.md",
+		`-modified:..2024-06-01 in:Home`:         "Home/Gamma.md",
+		`path:"Projects" "lives here" OR apples`: "Projects/Alpha.md",
+	}
+	for query, expected := range cases {
+		if got := strings.Join(search(query), ","); got != expected {
+			t.Fatalf("query %q: expected %q, got %q", query, expected, got)
+		}
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(`"exact phrase"`), nil)
+	var results []SearchResult
+	decodeJSONBody(t, rec, &results)
+	for _, result := range results {
+		if result.Path == "Home/Gamma.md" && (len(result.Matches) != 1 || result.Matches[0].Ranges[0] != (MatchRange{Start: 0, End: 12})) {
+			t.Fatalf("expected phrase highlight, got %#v", result.Matches)
+		}
+	}
+
+	for _, query := range []string{`"unterminated`, `OR apples`, `apples OR`, `a OR OR b`, `tag:`, `type:folder`, `modified:yesterday`, `modified:2025-02-01..2025-01-01`, `-draft`, `-draft OR -tag:old`} {
+		rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("query %q: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestSearchRegexAndCaseSensitive(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work.md"), "TODO(alice) ship it\ntodo(bob) later\nplain todo")
+	writeFile(t, filepath.Join(dir, "Home.md"), "Nothing To do here, go Home")
+
+	search := func(params string) []SearchResult {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?"+params, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", params, rec.Code, rec.Body.String())
+		}
+		var results []SearchResult
+		decodeJSONBody(t, rec, &results)
+		return results
+	}
+
+	results := search("mode=regex&query=" + url.QueryEscape(`TODO\(\w+\)`))
+	if len(results) != 1 || results[0].Path != "Work.md" || results[0].MatchCount != 2 {
+		t.Fatalf("unexpected regex results %#v", results)
+	}
+	if results[0].Matches[1].LineNumber != 2 || results[0].Matches[1].Ranges[0] != (MatchRange{Start: 0, End: 9}) {
+		t.Fatalf("unexpected regex match %#v", results[0].Matches[1])
+	}
+
+	results = search("mode=regex&caseSensitive=true&query=" + url.QueryEscape(`TODO\(\w+\)`))
+	if len(results) != 1 || results[0].MatchCount != 1 || results[0].Matches[0].LineNumber != 1 {
+		t.Fatalf("unexpected case-sensitive regex results %#v", results)
+	}
+
+	results = search("caseSensitive=true&query=Home")
+	if len(results) != 1 || results[0].Path != "Home.md" || results[0].MatchCount != 1 {
+		t.Fatalf("unexpected case-sensitive word results %#v", results)
+	}
+	results = search("caseSensitive=true&query=home")
+	if len(results) != 0 {
+		t.Fatalf("expected no lowercase match, got %#v", results)
+	}
+	results = search("caseSensitive=true&query=" + url.QueryEscape(`"To do"`))
+	if len(results) != 1 || results[0].Path != "Home.md" {
+		t.Fatalf("unexpected case-sensitive phrase results %#v", results)
+	}
+
+	for _, params := range []string{
+		"mode=regex&query=" + url.QueryEscape("(unclosed"),
+		"mode=regex&query=" + url.QueryEscape(strings.Repeat("a", maxRegexLength+1)),
+		"mode=regex&query=" + url.QueryEscape("(a{1000}){1000}"),
+		"mode=glob&query=todo",
+		"caseSensitive=maybe&query=todo",
+	} {
+		rec := doRequest(t, router, http.MethodGet, "/search?"+params, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", params, rec.Code)
+		}
+	}
+	rec := doRequest(t, router, http.MethodGet, "/search?mode=regex&query="+url.QueryEscape(strings.Repeat("a", maxRegexLength+1)), nil)
+	if !strings.Contains(rec.Body.String(), "limit is 1000 bytes") {
+		t.Fatalf("expected the error to name the size limit, got %s", rec.Body.String())
+	}
+}
+
+func TestSearchTasks(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), "# Plan\n\n- [ ] Call the plumber +house #errand @sam\n- [x] Pay invoice #finance\nplumber notes")
+	writeFile(t, filepath.Join(dir, "Home.md"), "nothing here")
+
+	search := func(params string) []SearchResult {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?"+params, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", params, rec.Code, rec.Body.String())
+		}
+		var results []SearchResult
+		decodeJSONBody(t, rec, &results)
+		return results
+	}
+
+	results := search("query=plumber")
+	if len(results) != 2 || results[0].Type != "note" || results[1].Type != "task" {
+		t.Fatalf("expected note then task, got %#v", results)
+	}
+	task := results[1]
+	if task.ID != "Work/Plan.md:3" || task.Name != "Call the plumber" || task.Path != "Work/Plan.md" {
+		t.Fatalf("unexpected task result %#v", task)
+	}
+	if len(task.Matches) != 1 || task.Matches[0].LineNumber != 3 || task.MatchCount != 1 {
+		t.Fatalf("unexpected task match %#v", task.Matches)
+	}
+
+	for _, query := range []string{"house", "errand", "sam", "tag:errand"} {
+		results = search("types=task&query=" + url.QueryEscape(query))
+		if len(results) != 1 || results[0].ID != "Work/Plan.md:3" {
+			t.Fatalf("%s: unexpected task results %#v", query, results)
+		}
+	}
+
+	results = search("types=note&query=plumber")
+	if len(results) != 1 || results[0].Type != "note" {
+		t.Fatalf("expected only the note, got %#v", results)
+	}
+	results = search("query=" + url.QueryEscape("invoice type:task"))
+	if len(results) != 1 || results[0].ID != "Work/Plan.md:4" {
+		t.Fatalf("unexpected type:task results %#v", results)
+	}
+	results = search("mode=regex&types=task&query=" + url.QueryEscape(`pl\w+er`))
+	if len(results) != 1 || results[0].ID != "Work/Plan.md:3" {
+		t.Fatalf("unexpected regex task results %#v", results)
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/search?types=task,folder&query=plumber", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestSearchReplace(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "People", "Alice.md"), "Met alice today.\r\nALICE said hi\r\n")
+	writeFile(t, filepath.Join(dir, "Projects", "Plan.md"), "#work\nAsk Alice about v1.2 and v1.3")
+	writeFile(t, filepath.Join(dir, "Other.md"), "no match here")
+
+	readNote := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	replace := func(payload map[string]any) ReplaceResponse {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodPost, "/search/replace", payload)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp ReplaceResponse
+		decodeJSONBody(t, rec, &resp)
+		return resp
+	}
+
+	preview := replace(map[string]any{"pattern": "alice", "replacement": "Alicia", "dryRun": true})
+	if !preview.DryRun || preview.Replacements != 3 || len(preview.Files) != 2 {
+		t.Fatalf("unexpected preview %#v", preview)
+	}
+	first := preview.Files[0]
+	if first.Path != "People/Alice.md" || len(first.Changes) != 2 || first.Changes[1] != (ReplaceChange{LineNumber: 2, Before: "ALICE said hi", After: "Alicia said hi"}) {
+		t.Fatalf("unexpected preview file %#v", first)
+	}
+	if readNote("People/Alice.md") != "Met alice today.\r\nALICE said hi\r\n" {
+		t.Fatalf("dry run should not write")
+	}
+
+	scoped := replace(map[string]any{"pattern": "alice", "replacement": "x", "tag": "#work", "dryRun": true})
+	if len(scoped.Files) != 1 || scoped.Files[0].Path != "Projects/Plan.md" {
+		t.Fatalf("unexpected tag scope %#v", scoped.Files)
+	}
+	scoped = replace(map[string]any{"pattern": "ALICE", "replacement": "x", "folder": "People", "caseSensitive": true, "dryRun": true})
+	if len(scoped.Files) != 1 || scoped.Files[0].Path != "People/Alice.md" || scoped.Files[0].Replacements != 1 {
+		t.Fatalf("unexpected folder scope %#v", scoped.Files)
+	}
+
+	stale := map[string]string{"People/Alice.md": "stale", "Projects/Plan.md": preview.Files[1].Hash}
+	rec := doRequest(t, router, http.MethodPost, "/search/replace", map[string]any{"pattern": "alice", "replacement": "Alicia", "baseHashes": stale})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	if readNote("Projects/Plan.md") != "#work\nAsk Alice about v1.2 and v1.3" {
+		t.Fatalf("conflict should not write")
+	}
+
+	hashes := map[string]string{}
+	for _, file := range preview.Files {
+		hashes[file.Path] = file.Hash
+	}
+	applied := replace(map[string]any{"pattern": "alice", "replacement": "Alicia", "baseHashes": hashes})
+	if applied.DryRun || applied.Replacements != 3 || len(applied.Files) != 2 || applied.Files[0].Changes != nil {
+		t.Fatalf("unexpected apply response %#v", applied)
+	}
+	if got := readNote("People/Alice.md"); got != "Met Alicia today.\r\nAlicia said hi\r\n" {
+		t.Fatalf("unexpected replaced content %q", got)
+	}
+	if applied.Files[0].Hash != noteHash([]byte(readNote("People/Alice.md"))) {
+		t.Fatalf("expected the new hash")
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, ".history", "People", "Alice.md"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one revision, got %v (%v)", entries, err)
+	}
+
+	replace(map[string]any{"pattern": `v(\d+)\.(\d+)`, "replacement": "v$1-$2", "mode": "regex"})
+	if got := readNote("Projects/Plan.md"); got != "#work\nAsk Alicia about v1-2 and v1-3" {
+		t.Fatalf("unexpected regex replacement %q", got)
+	}
+	literal := replace(map[string]any{"pattern": "$1", "replacement": "$2", "dryRun": true})
+	if len(literal.Files) != 0 {
+		t.Fatalf("expected no literal matches, got %#v", literal.Files)
+	}
+
+	for _, payload := range []map[string]any{
+		{"pattern": "", "replacement": "x"},
+		{"pattern": "a*", "replacement": "x", "mode": "regex"},
+		{"pattern": `\b`, "replacement": "|", "mode": "regex", "dryRun": true},
+		{"pattern": `(?m)$`, "replacement": "|", "mode": "regex", "dryRun": true},
+		{"pattern": "(", "replacement": "x", "mode": "regex"},
+		{"pattern": "a", "replacement": "x", "mode": "glob"},
+		{"pattern": "a", "replacement": "x", "folder": "Other.md"},
+	} {
+		rec := doRequest(t, router, http.MethodPost, "/search/replace", payload)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%v: expected status 400, got %d", payload, rec.Code)
+		}
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), "#work plan\n- [ ] follow up with sam")
+	writeFile(t, filepath.Join(dir, "Work", "Notes.md"), "#work notes")
+	writeFile(t, filepath.Join(dir, "Home.md"), "- [ ] follow up on the roof")
+
+	rec := doRequest(t, router, http.MethodPost, "/searches", map[string]any{"name": "Follow ups", "query": "follow", "types": []string{"task"}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created SavedSearch
+	decodeJSONBody(t, rec, &created)
+	if created.ID != "follow-ups" || created.Query != "follow" {
+		t.Fatalf("unexpected saved search %#v", created)
+	}
+	rec = doRequest(t, router, http.MethodPost, "/searches", map[string]any{"name": "Follow ups", "query": "tag:work"})
+	var second SavedSearch
+	decodeJSONBody(t, rec, &second)
+	if second.ID != "follow-ups-2" {
+		t.Fatalf("expected a unique id, got %q", second.ID)
+	}
+	if _, err := os.Stat(filepath.Join(dir, savedSearchesFileName)); err != nil {
+		t.Fatalf("expected saved searches file: %v", err)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/searches", map[string]any{"id": "follow-ups-2", "name": "Work"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodGet, "/searches", nil)
+	var searches []SavedSearch
+	decodeJSONBody(t, rec, &searches)
+	if len(searches) != 2 || searches[1].Name != "Work" || searches[1].Query != "tag:work" {
+		t.Fatalf("unexpected saved searches %#v", searches)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tree", nil)
+	var tree TreeNode
+	decodeJSONBody(t, rec, &tree)
+	last := tree.Children[len(tree.Children)-1]
+	if last.Type != "search" || last.Path != "search:follow-ups-2" || last.Children != nil {
+		t.Fatalf("expected saved searches as virtual folders, got %#v", tree.Children)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tree?search=follow-ups", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var folder TreeNode
+	decodeJSONBody(t, rec, &folder)
+	var paths []string
+	for _, child := range folder.Children {
+		paths = append(paths, child.Path)
+	}
+	sort.Strings(paths)
+	if folder.Type != "search" || strings.Join(paths, ",") != "Home.md,Work/Plan.md" {
+		t.Fatalf("unexpected saved search contents %#v", folder)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/notes", map[string]string{"path": "Work/Later.md", "content": "#work later"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodGet, "/tree?search=follow-ups-2", nil)
+	decodeJSONBody(t, rec, &folder)
+	if len(folder.Children) != 3 {
+		t.Fatalf("expected contents computed on demand, got %#v", folder.Children)
+	}
+
+	for _, payload := range []map[string]any{
+		{"name": "", "query": "x"},
+		{"name": "x", "query": ""},
+		{"name": "x", "query": "(", "mode": "regex"},
+		{"name": "x", "query": "x", "types": []string{"folder"}},
+		{"id": "mine", "name": "x", "query": "x"},
+	} {
+		rec := doRequest(t, router, http.MethodPost, "/searches", payload)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%v: expected status 400, got %d", payload, rec.Code)
+		}
+	}
+
+	rec = doRequest(t, router, http.MethodDelete, "/searches?id=follow-ups", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	for _, req := range [][2]string{
+		{http.MethodDelete, "/searches?id=follow-ups"},
+		{http.MethodGet, "/tree?search=follow-ups"},
+	} {
+		rec := doRequest(t, router, req[0], req[1], nil)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %s: expected status 404, got %d", req[0], req[1], rec.Code)
+		}
+	}
+}
+
+func TestQuickSwitch(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Quarterly Report.md"), "")
+	writeFile(t, filepath.Join(dir, "Projects", "Query Runner.md"), "")
+	writeFile(t, filepath.Join(dir, "Archive", "quarry road.md"), "")
+	writeFile(t, filepath.Join(dir, "Archive", "image.png"), "")
+
+	quick := func(params string) []QuickResult {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/notes/quick?"+params, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", params, rec.Code)
+		}
+		var results []QuickResult
+		decodeJSONBody(t, rec, &results)
+		return results
+	}
+
+	results := quick("query=qrep")
+	if len(results) == 0 || results[0].Path != "Projects/Quarterly Report.md" {
+		t.Fatalf("expected word-start match first, got %#v", results)
+	}
+	chars := []rune(results[0].Path)
+	var matched string
+	for _, pos := range results[0].Positions {
+		matched += string(chars[pos])
+	}
+	if matched != "QRep" {
+		t.Fatalf("expected positions on word starts, got %q (%v)", matched, results[0].Positions)
+	}
+
+	results = quick("query=arch")
+	if len(results) == 0 || results[0].Path != "Archive" || results[0].Type != "folder" {
+		t.Fatalf("expected folder match, got %#v", results)
+	}
+	for _, result := range quick("query=image") {
+		if result.Type == "note" && result.Path == "Archive/image.png" {
+			t.Fatalf("expected attachments to be skipped")
+		}
+	}
+	if results := quick("query=zzz"); len(results) != 0 {
+		t.Fatalf("expected no matches, got %#v", results)
+	}
+
+	if results := quick("query=qr"); results[0].Path == "Archive/quarry road.md" {
+		t.Fatalf("expected quarry road not to lead before it is opened")
+	}
+	doRequest(t, router, http.MethodGet, "/notes?path=Archive/quarry%20road.md", nil)
+	if results := quick("query=qr"); results[0].Path != "Archive/quarry road.md" {
+		t.Fatalf("expected recently opened note first, got %#v", results)
+	}
+	if results := quick(""); len(results) != 1 || results[0].Path != "Archive/quarry road.md" {
+		t.Fatalf("expected recent notes for an empty query, got %#v", results)
+	}
+	if results := quick("query=r&limit=1"); len(results) != 1 {
+		t.Fatalf("expected limit to apply, got %d", len(results))
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/notes/quick?query=a&limit=0", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestHierarchicalTags(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Acme.md"), "#project-x #2025 #work/client/acme #café\nword#no and #work/beta/\n- [ ] Call #Work/Acme #ü")
+	writeFile(t, filepath.Join(dir, "Work.md"), "#work")
+
+	rec := doRequest(t, router, http.MethodGet, "/tags", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var groups []TagGroup
+	decodeJSONBody(t, rec, &groups)
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Tag)
+	}
+	if strings.Join(names, ",") != "2025,café,project-x,work,ü" {
+		t.Fatalf("unexpected top-level tags %v", names)
+	}
+	work := groups[3]
+	if work.Count != 2 || len(work.Notes) != 1 || work.Notes[0].Path != "Work.md" || len(work.Children) != 3 {
+		t.Fatalf("unexpected work group %#v", work)
+	}
+	if acme := work.Children[0]; acme.Tag != "work/Acme" || len(acme.Notes) != 1 || acme.Notes[0].Path != "Acme.md" {
+		t.Fatalf("expected #Work/Acme under the work group, got %#v", acme)
+	}
+	client := work.Children[2]
+	if client.Tag != "work/client" || client.Name != "client" || client.Count != 1 || len(client.Notes) != 0 {
+		t.Fatalf("unexpected work/client group %#v", client)
+	}
+	if len(client.Children) != 1 || client.Children[0].Tag != "work/client/acme" || client.Children[0].Notes[0].Path != "Acme.md" {
+		t.Fatalf("unexpected work/client/acme group %#v", client.Children)
+	}
+	if work.Children[1].Tag != "work/beta" {
+		t.Fatalf("expected trailing slash to be dropped, got %#v", work.Children[1])
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].Text != "Call" || strings.Join(list.Tasks[0].Tags, ",") != "work/acme,ü" {
+		t.Fatalf("unexpected task tags %#v", list.Tasks)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/search?types=note&query="+url.QueryEscape("tag:work"), nil)
+	var results []SearchResult
+	decodeJSONBody(t, rec, &results)
+	if len(results) != 2 {
+		t.Fatalf("expected tag:work to match nested tags, got %#v", results)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/search?types=note&query="+url.QueryEscape("tag:work/client"), nil)
+	results = nil
+	decodeJSONBody(t, rec, &results)
+	if len(results) != 1 || results[0].Path != "Acme.md" {
+		t.Fatalf("unexpected tag:work/client results %#v", results)
+	}
+}
+
+func TestTagRename(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Standup.md"), "---\ntitle: Standup\ntags: [mtg, planning]\n---\nNotes #mtg and #MTG/weekly\nnot#mtg or #mtgs\n- [ ] Send notes #mtg")
+	writeFile(t, filepath.Join(dir, "Review.md"), "---\ntags: mtg meeting\n---\nReview #meeting")
+	writeFile(t, filepath.Join(dir, "Other.md"), "#planning only")
+
+	readNote := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(data)
+	}
+	rename := func(payload map[string]any) TagRenameResponse {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodPost, "/tags/rename", payload)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp TagRenameResponse
+		decodeJSONBody(t, rec, &resp)
+		return resp
+	}
+
+	preview := rename(map[string]any{"from": "#mtg", "to": "meeting", "dryRun": true})
+	if !preview.DryRun || len(preview.Files) != 2 || preview.Replacements != 5 {
+		t.Fatalf("unexpected preview %#v", preview)
+	}
+	review, standup := preview.Files[0], preview.Files[1]
+	if review.Path != "Review.md" || review.Frontmatter == nil || review.Frontmatter.After != "meeting" || len(review.Changes) != 0 {
+		t.Fatalf("unexpected Review.md preview %#v", review)
+	}
+	if standup.Frontmatter == nil || standup.Frontmatter.LineNumber != 3 || standup.Frontmatter.Before != "mtg, planning" {
+		t.Fatalf("unexpected frontmatter change %#v", standup.Frontmatter)
+	}
+	if len(standup.Changes) != 2 || standup.Changes[0] != (ReplaceChange{LineNumber: 5, Before: "Notes #mtg and #MTG/weekly", After: "Notes #meeting and #meeting/weekly"}) {
+		t.Fatalf("unexpected inline changes %#v", standup.Changes)
+	}
+	if !strings.Contains(readNote("Standup.md"), "#mtg and") {
+		t.Fatalf("dry run should not write")
+	}
+
+	applied := rename(map[string]any{"from": "mtg", "to": "meeting"})
+	if applied.DryRun || applied.Replacements != 5 || applied.Files[0].Frontmatter != nil {
+		t.Fatalf("unexpected apply response %#v", applied)
+	}
+	if got := readNote("Standup.md"); got != "---\ntitle: Standup\ntags: [meeting, planning]\n---\nNotes #meeting and #meeting/weekly\nnot#mtg or #mtgs\n- [ ] Send notes #meeting" {
+		t.Fatalf("unexpected Standup.md %q", got)
+	}
+	if got := readNote("Review.md"); got != "---\ntags: meeting\n---\nReview #meeting" {
+		t.Fatalf("expected merged frontmatter tags, got %q", got)
+	}
+
+	rename(map[string]any{"from": "meeting", "to": "work/meetings"})
+	rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 1 || strings.Join(list.Tasks[0].Tags, ",") != "work/meetings" {
+		t.Fatalf("expected task tag to be renamed, got %#v", list.Tasks)
+	}
+	if got := readNote("Standup.md"); !strings.Contains(got, "#work/meetings/weekly") {
+		t.Fatalf("expected nested tag to move with its parent, got %q", got)
+	}
+
+	for _, payload := range []map[string]any{
+		{"from": "", "to": "x"},
+		{"from": "a", "to": "a"},
+		{"from": "a", "to": "b c"},
+		{"from": "a", "to": "b//c"},
+	} {
+		rec := doRequest(t, router, http.MethodPost, "/tags/rename", payload)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%v: expected status 400, got %d", payload, rec.Code)
+		}
+	}
+}
+
+func TestTasksFilterSortAndPage(t *testing.T) {
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), strings.Join([]string{
+		"- [ ] Draft report +Work #client/acme @sam >2025-03-08 ^2",
+		"- [ ] Review budget +Work >2025-03-10 ^1",
+		"- [x] Book room +Work >2025-03-01",
+		"- [ ] Plan offsite +Work >2025-03-14 ^4",
+	}, "\n"))
+	writeFile(t, filepath.Join(dir, "Home.md"), "- [ ] Fix sink #home @alex\n- [ ] Call plumber ^3")
+
+	list := func(params string) TaskListResponse {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tasks?"+params, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", params, rec.Code, rec.Body.String())
+		}
+		var resp TaskListResponse
+		decodeJSONBody(t, rec, &resp)
+		return resp
+	}
+	texts := func(resp TaskListResponse) string {
+		var values []string
+		for _, task := range resp.Tasks {
+			values = append(values, task.Text)
+		}
+		return strings.Join(values, ",")
+	}
+
+	for params, want := range map[string]string{
+		"":                                "Fix sink,Call plumber,Draft report,Review budget,Book room,Plan offsite",
+		"project=%2Bwork&completed=false": "Draft report,Review budget,Plan offsite",
+		"tag=client":                      "Draft report",
+		"mention=Sam":                     "Draft report",
+		"minPriority=2&maxPriority=3":     "Call plumber,Draft report",
+		"due=overdue":                     "Draft report",
+		"due=today":                       "Review budget",
+		"due=week":                        "Review budget,Plan offsite",
+		"due=none":                        "Fix sink,Call plumber",
+		"dueAfter=2025-03-08&dueBefore=2025-03-10": "Draft report,Review budget",
+		"path=Work&query=PLAN":                     "Plan offsite",
+		"path=Home.md":                             "Fix sink,Call plumber",
+		"sort=due":                                 "Book room,Draft report,Review budget,Plan offsite,Call plumber,Fix sink",
+		"sort=priority":                            "Review budget,Draft report,Call plumber,Plan offsite,Book room,Fix sink",
+		"sort=project":                             "Book room,Draft report,Review budget,Plan offsite,Call plumber,Fix sink",
+		"sort=path":                                "Fix sink,Call plumber,Draft report,Review budget,Book room,Plan offsite",
+	} {
+		if got := texts(list(params)); got != want {
+			t.Fatalf("%s: expected %s, got %s", params, want, got)
+		}
+	}
+
+	page := list("sort=due&limit=2&offset=1")
+	if page.Total != 6 || texts(page) != "Draft report,Review budget" {
+		t.Fatalf("unexpected page %d %s", page.Total, texts(page))
+	}
+	page = list("limit=5&offset=10")
+	if page.Total != 6 || len(page.Tasks) != 0 {
+		t.Fatalf("expected an empty page, got %#v", page)
+	}
+
+	for _, params := range []string{
+		"completed=maybe", "minPriority=0", "maxPriority=6", "dueBefore=tomorrow",
+		"due=later", "sort=text", "limit=0", "limit=1001", "offset=-1", "path=..",
+	} {
+		rec := doRequest(t, router, http.MethodGet, "/tasks?"+params, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", params, rec.Code)
+		}
+	}
+}
+
+func TestTaskCreateUpdateDelete(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Plan.md"), "# Plan\n\n## Today\n- [ ] Existing\n\n## Later\n- [ ] Someday\n")
+
+	rec := doRequest(t, router, http.MethodPost, "/tasks", map[string]any{
+		"path":     "Plan",
+		"heading":  "today",
+		"text":     "Draft report",
+		"project":  "+Work",
+		"tags":     []string{"#client/acme"},
+		"mentions": []string{"Sam"},
+		"due":      "2025-03-14",
+		"priority": 2,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created TaskItem
+	decodeJSONBody(t, rec, &created)
+	if created.LineNumber != 5 || created.Project != "work" || created.DueDateISO != "2025-03-14" || created.Priority != 2 {
+		t.Fatalf("unexpected task %+v", created)
+	}
+	want := "# Plan\n\n## Today\n- [ ] Existing\n- [ ] Draft report +Work #client/acme @Sam >2025-03-14 ^2\n\n## Later\n- [ ] Someday\n"
+	data, _ := os.ReadFile(filepath.Join(dir, "Plan.md"))
+	if string(data) != want {
+		t.Fatalf("unexpected note after create:\n%s", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/tasks", map[string]any{"heading": "Captured", "text": "Call plumber"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected inbox status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Inbox.md"))
+	if string(data) != "## Captured\n- [ ] Call plumber\n" {
+		t.Fatalf("unexpected inbox note:\n%q", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks", map[string]any{
+		"path":       "Plan.md",
+		"lineNumber": created.LineNumber,
+		"lineHash":   created.LineHash,
+		"text":       "Send report",
+		"tags":       []string{},
+		"priority":   0,
+		"completed":  true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var updated TaskItem
+	decodeJSONBody(t, rec, &updated)
+	if !updated.Completed || updated.Text != "Send report" || len(updated.Tags) != 0 {
+		t.Fatalf("unexpected updated task %+v", updated)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Plan.md"))
+	if !strings.Contains(string(data), "- [x] Send report +Work @Sam >2025-03-14\n") {
+		t.Fatalf("unexpected note after update:\n%s", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks", map[string]any{
+		"path":       "Plan.md",
+		"lineNumber": created.LineNumber,
+		"lineHash":   created.LineHash,
+		"text":       "Stale",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected stale hash status 400, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/tasks", map[string]any{
+		"path":       "Plan.md",
+		"lineNumber": updated.LineNumber,
+		"lineHash":   updated.LineHash,
+		"project":    "two words",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid project status 400, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodDelete, "/tasks?path=Plan.md&lineNumber="+strconv.Itoa(updated.LineNumber)+"&lineHash="+updated.LineHash, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected delete status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Plan.md"))
+	if string(data) != "# Plan\n\n## Today\n- [ ] Existing\n\n## Later\n- [ ] Someday\n" {
+		t.Fatalf("unexpected note after delete:\n%s", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/tasks", map[string]any{"path": "Missing.md", "text": "Nope"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected missing note status 404, got %d", rec.Code)
+	}
+}
+
+func TestTaskDeleteWithSubtasks(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Plan.md")
+	writeFile(t, notePath, strings.Join([]string{
+		"- [ ] Other",
+		"- [ ] Parent",
+		"  - [ ] Child",
+		"    - [x] Grandchild",
+		"  Note under parent",
+		"- [ ] Sibling",
+	}, "\n"))
+
+	hash := hashLine("- [ ] Parent")
+	rec := doRequest(t, router, http.MethodDelete, "/tasks?path=Plan.md&lineNumber=2&lineHash="+hash, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409 without cascade, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodDelete, "/tasks?path=Plan.md&lineNumber=2&lineHash="+hash+"&cascade=maybe", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid cascade, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodDelete, "/tasks?path=Plan.md&lineNumber=2&lineHash="+hash+"&cascade=true", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(notePath)
+	if string(data) != "- [ ] Other\n  Note under parent\n- [ ] Sibling" {
+		t.Fatalf("unexpected note after cascade delete:\n%s", data)
+	}
+}
+
+func TestRelativeDueDates(t *testing.T) {
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Plan.md"), strings.Join([]string{
+		"- [ ] Soon >+3d",
+		"- [ ] Monday >next-week",
+		"- [ ] Friday >Fri",
+		"- [ ] Later >+1m",
+		"- [ ] Month >next-month",
+		"- [ ] Unknown >someday",
+	}, "\n"))
+	writeFile(t, filepath.Join(dir, "Daily", "2025-03-07.md"), "- [ ] Weekend >tomorrow\n- [ ] Next Friday >friday")
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	due := make(map[string]string)
+	for _, task := range list.Tasks {
+		due[task.Text] = task.DueDateISO
+	}
+	for text, want := range map[string]string{
+		"Soon":        "2025-03-13",
+		"Monday":      "2025-03-17",
+		"Friday":      "2025-03-14",
+		"Later":       "2025-04-10",
+		"Month":       "2025-04-01",
+		"Unknown":     "",
+		"Weekend":     "2025-03-08",
+		"Next Friday": "2025-03-14",
+	} {
+		if due[text] != want {
+			t.Fatalf("%s: expected due %q, got %q", text, want, due[text])
+		}
+	}
+	if !strings.Contains(list.Notice, "someday") {
+		t.Fatalf("expected notice for someday, got %q", list.Notice)
+	}
+
+	resolved, ok := resolveRelativeDate("+1m", time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local))
+	if !ok || resolved.Format("2006-01-02") != "2025-02-28" {
+		t.Fatalf("expected month offsets to stop at month end, got %v", resolved)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "Plan.md", "content": "- [ ] Soon >+3d\n"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "Plan.md"))
+	if string(data) != "- [ ] Soon >+3d\n" {
+		t.Fatalf("expected relative date to be kept by default, got %q", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"rewriteRelativeDates": true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected settings status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":    "Daily/2025-03-07.md",
+		"content": "- [ ] Weekend >tomorrow\r\n- [ ] Second >tomorrow.\r\nplain >tomorrow\r\n```\r\n- [ ] x >tomorrow\r\n```\r\n",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var saved map[string]string
+	decodeJSONBody(t, rec, &saved)
+	want := "- [ ] Weekend >2025-03-08\r\n- [ ] Second >2025-03-08.\r\nplain >tomorrow\r\n```\r\n- [ ] x >tomorrow\r\n```\r\n"
+	if saved["content"] != want {
+		t.Fatalf("expected rewritten content in response, got %q", saved["content"])
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Daily", "2025-03-07.md"))
+	if string(data) != want || saved["hash"] != noteHash(data) {
+		t.Fatalf("unexpected saved note %q", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/tasks", map[string]any{"path": "Plan.md", "text": "Call", "due": ">tue"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Plan.md"))
+	if !strings.HasSuffix(string(data), "- [ ] Call >2025-03-11\n") {
+		t.Fatalf("expected created task due date to be rewritten, got %q", data)
+	}
+}
+
+func TestRecurringTasks(t *testing.T) {
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Chores.md")
+	writeFile(t, notePath, strings.Join([]string{
+		"- [ ] Water plants *every:week >2025-03-08 #home",
+		"- [ ] Pay rent *every:month:15",
+		"- [ ] Broken *every:fortnight",
+		"  - [ ] Stretch *every:2d",
+	}, "\r\n"))
+
+	tasks := func() map[int]TaskItem {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+		var list TaskListResponse
+		decodeJSONBody(t, rec, &list)
+		byLine := make(map[int]TaskItem)
+		for _, task := range list.Tasks {
+			byLine[task.LineNumber] = task
+		}
+		return byLine
+	}
+	toggle := func(task TaskItem, completed bool) map[string]json.RawMessage {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+			"path":       task.Path,
+			"lineNumber": task.LineNumber,
+			"lineHash":   task.LineHash,
+			"completed":  completed,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp map[string]json.RawMessage
+		decodeJSONBody(t, rec, &resp)
+		return resp
+	}
+
+	before := tasks()
+	if before[1].Recurrence != "week" || before[1].Text != "Water plants" || before[3].Recurrence != "" || before[3].Text != "Broken" {
+		t.Fatalf("unexpected recurrence parsing %+v %+v", before[1], before[3])
+	}
+
+	resp := toggle(before[1], true)
+	var next TaskItem
+	if err := json.Unmarshal(resp["next"], &next); err != nil {
+		t.Fatalf("expected next task: %v", err)
+	}
+	if next.LineNumber != 2 || next.Completed || next.DueDateISO != "2025-03-15" || next.Recurrence != "week" {
+		t.Fatalf("unexpected next task %+v", next)
+	}
+	if resp := toggle(tasks()[1], true); resp["next"] != nil {
+		t.Fatalf("expected no copy when the task was already completed")
+	}
+	toggle(tasks()[3], true)
+	toggle(tasks()[6], true)
+	if resp := toggle(tasks()[5], true); resp["next"] != nil {
+		t.Fatalf("expected no copy for an unknown rule")
+	}
+
+	data, _ := os.ReadFile(notePath)
+	want := strings.Join([]string{
+		"- [x] Water plants *every:week >2025-03-08 #home",
+		"- [ ] Water plants *every:week >2025-03-15 #home",
+		"- [x] Pay rent *every:month:15",
+		"- [ ] Pay rent *every:month:15 >2025-03-15",
+		"- [x] Broken *every:fortnight",
+		"  - [x] Stretch *every:2d",
+		"  - [ ] Stretch *every:2d >2025-03-12",
+	}, "\r\n")
+	if string(data) != want {
+		t.Fatalf("unexpected note after toggles:\n%q", data)
+	}
+
+	rent := tasks()[4]
+	rec := doRequest(t, router, http.MethodPatch, "/tasks", map[string]any{
+		"path":       rent.Path,
+		"lineNumber": rent.LineNumber,
+		"lineHash":   rent.LineHash,
+		"recurrence": "*every:2m:31",
+		"completed":  true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if after := tasks(); after[5].Text != "Pay rent" || after[5].DueDateISO != "2025-03-31" || after[5].Completed {
+		t.Fatalf("expected PATCH completion to add the next occurrence, got %+v", after[5])
+	}
+
+	for rule, want := range map[string]string{
+		"month:31": "2025-02-28",
+		"month":    "2025-02-28",
+		"week:fri": "2025-02-07",
+		"3w":       "2025-02-21",
+		"yearly":   "2026-01-31",
+		"2d":       "2025-02-02",
+	} {
+		rec, ok := parseRecurrence(rule)
+		if !ok {
+			t.Fatalf("%s: expected a valid rule", rule)
+		}
+		if got := rec.next(time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)).Format("2006-01-02"); got != want {
+			t.Fatalf("%s: expected %s, got %s", rule, want, got)
+		}
+	}
+	for _, rule := range []string{"", "0d", "day:3", "month:32", "week:someday", "week:fri:1"} {
+		if _, ok := parseRecurrence(rule); ok {
+			t.Fatalf("%s: expected an invalid rule", rule)
+		}
+	}
+}
+
+func TestTaskHierarchy(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Trip.md")
+	writeFile(t, notePath, strings.Join([]string{
+		"# Trip",
+		"- [ ] Pack +Travel",
+		"  - [x] Clothes",
+		"  - [ ] Toiletries",
+		"    - [ ] Toothbrush",
+		"  Remember the adapter",
+		"  - [ ] Passport",
+		"Unrelated paragraph",
+		"  - [ ] Orphan",
+		"- [ ] Call hotel *every:week >2025-03-08",
+		"\t- [ ] Confirm dates",
+	}, "\n"))
+
+	tasks := func() map[int]TaskItem {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+		var list TaskListResponse
+		decodeJSONBody(t, rec, &list)
+		byLine := make(map[int]TaskItem)
+		for _, task := range list.Tasks {
+			byLine[task.LineNumber] = task
+		}
+		return byLine
+	}
+
+	byLine := tasks()
+	pack := byLine[2]
+	if strings.Join(pack.Children, ",") != "Trip.md:3,Trip.md:4,Trip.md:7" {
+		t.Fatalf("unexpected children %v", pack.Children)
+	}
+	if pack.Progress == nil || pack.Progress.Done != 1 || pack.Progress.Total != 4 {
+		t.Fatalf("unexpected progress %+v", pack.Progress)
+	}
+	if byLine[5].ParentID != "Trip.md:4" || byLine[7].ParentID != "Trip.md:2" || byLine[9].ParentID != "" || byLine[11].ParentID != "Trip.md:10" {
+		t.Fatalf("unexpected parents %q %q %q %q", byLine[5].ParentID, byLine[7].ParentID, byLine[9].ParentID, byLine[11].ParentID)
+	}
+	if byLine[3].Progress != nil || byLine[3].Children != nil {
+		t.Fatalf("expected a leaf task without progress, got %+v", byLine[3])
+	}
+
+	rec := doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+		"path":       "Trip.md",
+		"lineNumber": pack.LineNumber,
+		"lineHash":   pack.LineHash,
+		"completed":  true,
+		"cascade":    true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]any
+	decodeJSONBody(t, rec, &resp)
+	if resp["cascaded"] != float64(3) {
+		t.Fatalf("expected 3 cascaded subtasks, got %v", resp["cascaded"])
+	}
+	byLine = tasks()
+	if !byLine[5].Completed || !byLine[7].Completed || byLine[9].Completed {
+		t.Fatalf("expected only the subtasks to be completed")
+	}
+	if progress := byLine[2].Progress; progress.Done != 4 || progress.Total != 4 {
+		t.Fatalf("unexpected progress after cascade %+v", progress)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+		"path":       "Trip.md",
+		"lineNumber": 2,
+		"lineHash":   byLine[2].LineHash,
+		"completed":  false,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if byLine = tasks(); byLine[2].Completed || !byLine[3].Completed || !byLine[4].Completed {
+		t.Fatalf("expected reopening without cascade to leave subtasks alone")
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+		"path":       "Trip.md",
+		"lineNumber": 10,
+		"lineHash":   byLine[10].LineHash,
+		"completed":  true,
+		"cascade":    true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, _ := os.ReadFile(notePath)
+	if !strings.HasSuffix(string(data), "- [x] Call hotel *every:week >2025-03-08\n\t- [x] Confirm dates\n- [ ] Call hotel *every:week >2025-03-15\n\t- [ ] Confirm dates") {
+		t.Fatalf("expected the next occurrence and open subtasks after the subtasks, got %q", data)
+	}
+}
+
+func TestTaskUpdateKeepsLineLayout(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Launch.md")
+	writeFile(t, notePath, "Intro\r\n  - [ ] Email @alice about the #launch plan for +Work tomorrow >2025-03-14.\r\n")
+
+	patch := func(body map[string]any) TaskItem {
+		t.Helper()
+		data, _ := os.ReadFile(notePath)
+		body["path"] = "Launch.md"
+		body["lineNumber"] = 2
+		body["lineHash"] = hashLine(strings.TrimSuffix(strings.Split(string(data), "\n")[1], "\r"))
+		rec := doRequest(t, router, http.MethodPatch, "/tasks", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var task TaskItem
+		decodeJSONBody(t, rec, &task)
+		return task
+	}
+	line := func() string {
+		t.Helper()
+		data, _ := os.ReadFile(notePath)
+		return strings.Split(string(data), "\n")[1]
+	}
+
+	if task := patch(map[string]any{"priority": 2}); task.Priority != 2 {
+		t.Fatalf("expected priority 2, got %d", task.Priority)
+	}
+	if got := line(); got != "  - [ ] Email @alice about the #launch plan for +Work tomorrow >2025-03-14. ^2\r" {
+		t.Fatalf("expected a priority-only edit to keep the line, got %q", got)
+	}
+
+	patch(map[string]any{"priority": 4, "due": "2025-03-20", "tags": []string{"Launch", "q3"}, "mentions": []string{}, "project": ""})
+	if got := line(); got != "  - [ ] Email about the #Launch plan for tomorrow >2025-03-20. ^4 #q3\r" {
+		t.Fatalf("unexpected line after marker edits %q", got)
+	}
+
+	patch(map[string]any{"text": "Send the launch email", "completed": true})
+	if got := line(); got != "  - [x] Send the launch email #Launch >2025-03-20. ^4 #q3\r" {
+		t.Fatalf("unexpected line after text edit %q", got)
+	}
+}
+
+func TestTagRenameFoldedRuneLengths(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Units.md")
+	writeFile(t, notePath, "Temperatures #k and #k/sub but not #kx")
+
+	rec := doRequest(t, router, http.MethodPost, "/tags/rename", map[string]any{"from": "\u212a", "to": "kelvin"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(notePath)
+	if string(data) != "Temperatures #kelvin and #kelvin/sub but not #kx" {
+		t.Fatalf("unexpected note after rename %q", data)
+	}
+
+	if !tagMatchesFold("\u212a/sub", "k") || tagMatchesFold("k", "\u212ax") {
+		t.Fatalf("expected folded matching to compare runes")
+	}
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	a := make([]string, 3000)
+	b := make([]string, 3000)
+	for i := range a {
+		a[i] = "old " + strconv.Itoa(i)
+		b[i] = "new " + strconv.Itoa(i)
+	}
+	b[1500] = a[1500]
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Fatalf("expected a bounded diff, allocated %d bytes", allocated)
+	}
+
+	removed, added := 0, 0
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			if a[op.aIdx] != op.text {
+				t.Fatalf("removed line %d does not match", op.aIdx)
+			}
+			removed++
+		case '+':
+			if b[op.bIdx] != op.text {
+				t.Fatalf("added line %d does not match", op.bIdx)
+			}
+			added++
+		}
+	}
+	if removed != 3000 || added != 3000 {
+		t.Fatalf("expected the whole block to be replaced, got -%d +%d", removed, added)
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	for i := 1; i <= 5; i++ {
+		writeFile(t, filepath.Join(dir, "Note"+strconv.Itoa(i)+".md"), "shared words")
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query=shared&limit=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var matches []SearchResult
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 2 || matches[0].MatchCount != 1 {
+		t.Fatalf("expected two results with matches, got %#v", matches)
+	}
+
+	for _, limit := range []string{"0", "501", "many"} {
+		rec := doRequest(t, router, http.MethodGet, "/search?query=shared&limit="+limit, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for limit %s, got %d", limit, rec.Code)
+		}
+	}
+}
+
+func TestTagRenameSkipsCodeAndKeepsHashForm(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Code.md")
+	writeFile(t, notePath, strings.Join([]string{
+		"---",
+		`tags: ["#mtg", home]`,
+		"---",
+		"Real #mtg and `code #mtg` and ``tick ` #mtg`` then #mtg",
+		"```",
+		"#mtg in a fence",
+		"```",
+		"~~~",
+		"#mtg in a tilde fence",
+		"~~~",
+		"Unmatched ` #mtg",
+	}, "\n"))
+	inlinePath := filepath.Join(dir, "Inline.md")
+	writeFile(t, inlinePath, "---\ntags: \"#mtg #home\"\n---\nbody")
+
+	rec := doRequest(t, router, http.MethodPost, "/tags/rename", map[string]any{"from": "mtg", "to": "meeting"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(notePath)
+	want := strings.Join([]string{
+		"---",
+		`tags: ['#meeting', home]`,
+		"---",
+		"Real #meeting and `code #mtg` and ``tick ` #mtg`` then #meeting",
+		"```",
+		"#mtg in a fence",
+		"```",
+		"~~~",
+		"#mtg in a tilde fence",
+		"~~~",
+		"Unmatched ` #meeting",
+	}, "\n")
+	if string(data) != want {
+		t.Fatalf("unexpected note after rename %q", data)
+	}
+	data, _ = os.ReadFile(inlinePath)
+	if !strings.Contains(string(data), "#meeting #home") {
+		t.Fatalf("expected inline frontmatter tags to keep #, got %q", data)
+	}
+}
+
+func TestTagMergeDropsDuplicatesAndTagsSkipCode(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Merge.md")
+	writeFile(t, notePath, strings.Join([]string{
+		"#mtg #meeting notes",
+		"Agenda #Meeting and #mtg",
+		"`#mtg` stays",
+		"~~~",
+		"#fenced",
+		"~~~",
+	}, "\n"))
+
+	for i, line := range []string{"#mtg #meeting notes", "Agenda #Meeting and #mtg", "#mtg #Mtg notes"} {
+		got, count := renameInlineTags(line, "mtg", "meeting")
+		want := []string{"#meeting notes", "Agenda #Meeting and", "#meeting notes"}[i]
+		wantCount := []int{1, 1, 2}[i]
+		if got != want || count != wantCount {
+			t.Fatalf("expected %q, got %q (%d)", want, got, count)
+		}
+	}
+
+	tagNames := func() []string {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tags", nil)
+		var groups []TagGroup
+		decodeJSONBody(t, rec, &groups)
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Tag)
+		}
+		return names
+	}
+	if got := strings.Join(tagNames(), ","); got != "Meeting,mtg" {
+		t.Fatalf("expected tags outside code only, got %s", got)
+	}
+
+	rec := doRequest(t, router, http.MethodPost, "/tags/rename", map[string]any{"from": "mtg", "to": "meeting"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := os.ReadFile(notePath)
+	if !strings.HasPrefix(string(data), "#meeting notes\nAgenda #Meeting and\n`#mtg` stays") {
+		t.Fatalf("unexpected note after merge %q", data)
+	}
+	for _, name := range tagNames() {
+		if strings.EqualFold(name, "mtg") {
+			t.Fatalf("expected mtg to leave the tag list after the rename")
+		}
+	}
+}
+
+func TestWarnReservedDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, trashDirName, "20250301T090000.000000000-0123abcd", "Old.md"), "deleted")
+	writeFile(t, filepath.Join(dir, trashDirName, "20250301T090000.000000000-0123abcd.json"), "{}")
+	writeFile(t, filepath.Join(dir, historyDirName, "Plan.md", "20250301T090000.000000000_update.md"), "old")
+	writeFile(t, filepath.Join(dir, indexDirName, searchIndexFile), "{}")
+
+	var logs bytes.Buffer
+	New(dir, slog.New(slog.NewTextHandler(&logs, nil)))
+	if strings.Contains(logs.String(), "reserved folder") {
+		t.Fatalf("expected no warning for app files, got %s", logs.String())
+	}
+
+	writeFile(t, filepath.Join(dir, trashDirName, "Mine.md"), "my own trash note")
+	writeFile(t, filepath.Join(dir, indexDirName, "Book.md"), "my own index")
+	logs.Reset()
+	New(dir, slog.New(slog.NewTextHandler(&logs, nil)))
+	for _, name := range []string{trashDirName, indexDirName} {
+		if !strings.Contains(logs.String(), "folder="+name+" files=1") {
+			t.Fatalf("expected a warning for %s, got %s", name, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "folder="+historyDirName) {
+		t.Fatalf("expected no warning for history, got %s", logs.String())
+	}
+}
+
+func TestLinkRewritesSkipChangedNotes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Target.md"), "target")
+	writeFile(t, filepath.Join(dir, "Kept.md"), "See [[Target]]")
+	writeFile(t, filepath.Join(dir, "Edited.md"), "See [[Target]]")
+	s := &Server{notesDir: dir, logger: slog.Default(), search: newSearchIndex(), recent: &recentNotes{}}
+
+	rewrites, err := s.planLinkRewrites(map[string]string{"Target.md": "Moved.md"})
+	if err != nil || len(rewrites) != 2 {
+		t.Fatalf("expected two planned rewrites, got %#v (%v)", rewrites, err)
+	}
+	writeFile(t, filepath.Join(dir, "Edited.md"), "See [[Target]] and more")
+	if err := os.Rename(filepath.Join(dir, "Target.md"), filepath.Join(dir, "Moved.md")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	updated, skipped := s.applyLinkRewrites(rewrites)
+	if strings.Join(updated, ",") != "Kept.md" || strings.Join(skipped, ",") != "Edited.md" {
+		t.Fatalf("expected the edited note to be skipped, got updated %v skipped %v", updated, skipped)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "Edited.md"))
+	if string(data) != "See [[Target]] and more" {
+		t.Fatalf("expected concurrent edit to be kept, got %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Kept.md"))
+	if string(data) != "See [[Moved]]" {
+		t.Fatalf("expected unchanged note to be rewritten, got %q", data)
+	}
+}
+
+func TestTrashRestoreTrimsIDAndKeepsNoteExtension(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "gone.md"), "gone")
+	rec := doRequest(t, router, http.MethodDelete, "/notes?path=gone.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/trash", nil)
+	var items []TrashItem
+	decodeJSONBody(t, rec, &items)
+	if len(items) != 1 {
+		t.Fatalf("expected 1 trash item, got %#v", items)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/trash/restore", map[string]string{"id": " " + items[0].ID + " ", "path": "Restored/foo"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var restored map[string]string
+	decodeJSONBody(t, rec, &restored)
+	if restored["path"] != "Restored/foo.md" {
+		t.Fatalf("expected restored note to keep .md, got %#v", restored)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Restored", "foo.md")); err != nil {
+		t.Fatalf("expected restored note on disk: %v", err)
+	}
+}
+
+func TestCloseSavesSearchIndex(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Garden.md"), "planting")
+	s := New(dir)
+	router := s.Routes()
+	doRequest(t, router, http.MethodGet, "/search?query=planting", nil)
+	rec := doRequest(t, router, http.MethodPost, "/notes", map[string]string{"path": "Orchids.md", "content": "orchids"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+	if s.search.saveTimer == nil {
+		t.Fatalf("expected a pending index save after a change")
+	}
+
+	s.Close()
+	if s.search.saveTimer != nil || s.search.dirty {
+		t.Fatalf("expected close to stop the timer and save the index")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexDirName, searchIndexFile))
+	if err != nil || !strings.Contains(string(data), "Orchids.md") {
+		t.Fatalf("expected saved index to include the new note: %v", err)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{"path": "Orchids.md", "content": "roses"})
+	if rec.Code != http.StatusOK || s.search.saveTimer != nil {
+		t.Fatalf("expected no save to be scheduled after close")
+	}
+}
+
+func TestSavedSearchConcurrentCreates(t *testing.T) {
+	_, router := setupTestRouter(t)
+	const count = 20
+	codes := make([]int, count)
+	var wg sync.WaitGroup
+	for i := range count {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doRequest(t, router, http.MethodPost, "/searches", map[string]any{"name": "Search " + strconv.Itoa(i), "query": "x"})
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusCreated {
+			t.Fatalf("create %d: expected status 201, got %d", i, code)
+		}
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/searches", nil)
+	var searches []SavedSearch
+	decodeJSONBody(t, rec, &searches)
+	if len(searches) != count {
+		t.Fatalf("expected %d saved searches, got %d", count, len(searches))
+	}
+}
+
+func TestRecurringTaskRepeatsSubtasks(t *testing.T) {
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Weekly.md")
+	writeFile(t, notePath, "- [ ] Review *every:week >2025-03-10\r\n  - [x] Inbox\r\n  - [ ] Calendar")
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	review := list.Tasks[0]
+	rec = doRequest(t, router, http.MethodPatch, "/tasks", map[string]any{
+		"path":       review.Path,
+		"lineNumber": review.LineNumber,
+		"lineHash":   review.LineHash,
+		"completed":  true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	data, _ := os.ReadFile(notePath)
+	want := strings.Join([]string{
+		"- [x] Review *every:week >2025-03-10",
+		"  - [x] Inbox",
+		"  - [ ] Calendar",
+		"- [ ] Review *every:week >2025-03-17",
+		"  - [ ] Inbox",
+		"  - [ ] Calendar",
+	}, "\r\n")
+	if string(data) != want {
+		t.Fatalf("expected the subtasks to repeat unchecked, got %q", data)
+	}
+}
+
+func TestRecurringTaskRecompletedKeepsOneOccurrence(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	notePath := filepath.Join(dir, "Garden.md")
+	writeFile(t, notePath, "- [ ] Water >2025-01-01 *every:week\n  - [ ] Fill can")
+
+	toggle := func(completed bool) {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
+		var list TaskListResponse
+		decodeJSONBody(t, rec, &list)
+		water := list.Tasks[0]
+		rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+			"path":       water.Path,
+			"lineNumber": water.LineNumber,
+			"lineHash":   water.LineHash,
+			"completed":  completed,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	toggle(true)
+	toggle(false)
+	toggle(true)
+
+	data, _ := os.ReadFile(notePath)
+	want := strings.Join([]string{
+		"- [x] Water >2025-01-01 *every:week",
+		"  - [ ] Fill can",
+		"- [ ] Water >2025-01-08 *every:week",
+		"  - [ ] Fill can",
+	}, "\n")
+	if string(data) != want {
+		t.Fatalf("expected a single next occurrence, got %q", data)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"strings"
 )
 
-const settingsFileName = "settings.json"
+const (
+	settingsFileName = "settings.json"
+	defaultTaskInbox = "Inbox.md"
+)
 
 type Settings struct {
 	Version                 int    `json:"version"`
@@ -21,6 +24,10 @@ type Settings struct {
 	DefaultFolder           string `json:"defaultFolder"`
 	DailyFolder             string `json:"dailyFolder"`
 	ShowTemplates           bool   `json:"showTemplates"`
+	HistoryMaxRevisions     int    `json:"historyMaxRevisions"`
+	HistoryMaxAgeDays       int    `json:"historyMaxAgeDays"`
+	TaskInbox               string `json:"taskInbox"`
+	RewriteRelativeDates    bool   `json:"rewriteRelativeDates"`
 }
 
 type SettingsResponse struct {
@@ -37,6 +44,10 @@ type SettingsPayload struct {
 	DefaultFolder           *string `json:"defaultFolder,omitempty"`
 	DailyFolder             *string `json:"dailyFolder,omitempty"`
 	ShowTemplates           *bool   `json:"showTemplates,omitempty"`
+	HistoryMaxRevisions     *int    `json:"historyMaxRevisions,omitempty"`
+	HistoryMaxAgeDays       *int    `json:"historyMaxAgeDays,omitempty"`
+	TaskInbox               *string `json:"taskInbox,omitempty"`
+	RewriteRelativeDates    *bool   `json:"rewriteRelativeDates,omitempty"`
 }
 
 func (s *Server) handleSettingsGet(w http.ResponseWriter, r *http.Request) {
@@ -71,7 +82,7 @@ func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	changed := make([]string, 0, 8)
+	changed := make([]string, 0, 10)
 	if payload.DarkMode != nil {
 		settings.DarkMode = *payload.DarkMode
 		changed = append(changed, "darkMode")
@@ -104,6 +115,22 @@ func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
 		settings.ShowTemplates = *payload.ShowTemplates
 		changed = append(changed, "showTemplates")
 	}
+	if payload.HistoryMaxRevisions != nil {
+		settings.HistoryMaxRevisions = *payload.HistoryMaxRevisions
+		changed = append(changed, "historyMaxRevisions")
+	}
+	if payload.HistoryMaxAgeDays != nil {
+		settings.HistoryMaxAgeDays = *payload.HistoryMaxAgeDays
+		changed = append(changed, "historyMaxAgeDays")
+	}
+	if payload.TaskInbox != nil {
+		settings.TaskInbox = *payload.TaskInbox
+		changed = append(changed, "taskInbox")
+	}
+	if payload.RewriteRelativeDates != nil {
+		settings.RewriteRelativeDates = *payload.RewriteRelativeDates
+		changed = append(changed, "rewriteRelativeDates")
+	}
 	if err := s.saveSettings(settings); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to save settings")
 		return
@@ -132,6 +159,10 @@ func (s *Server) loadSettings() (Settings, string, error) {
 				DefaultFolder:           "",
 				DailyFolder:             "",
 				ShowTemplates:           true,
+				HistoryMaxRevisions:     defaultHistoryMaxRevs,
+				HistoryMaxAgeDays:       0,
+				TaskInbox:               defaultTaskInbox,
+				RewriteRelativeDates:    false,
 			}
 			if err := os.MkdirAll(s.notesDir, 0o755); err != nil {
 				return settings, "", err
@@ -161,6 +192,12 @@ func (s *Server) loadSettings() (Settings, string, error) {
 	if settings.SidebarWidth == 0 {
 		settings.SidebarWidth = 300
 	}
+	if settings.HistoryMaxRevisions == 0 {
+		settings.HistoryMaxRevisions = defaultHistoryMaxRevs
+	}
+	if settings.TaskInbox == "" {
+		settings.TaskInbox = defaultTaskInbox
+	}
 	if settings.DefaultFolder == "." {
 		settings.DefaultFolder = ""
 	}
@@ -181,7 +218,7 @@ func (s *Server) saveSettings(settings Settings) error {
 		return err
 	}
 	data = append(data, '\n')
-	return os.WriteFile(s.settingsFilePath(), data, 0o644)
+	return writeFileAtomic(s.settingsFilePath(), data, 0o644)
 }
 
 func validateSettingsPayload(payload SettingsPayload) error {
@@ -201,6 +238,14 @@ func validateSettingsPayload(payload SettingsPayload) error {
 			return errors.New("sidebarWidth must be between 220 and 600")
 		}
 	}
+	if payload.HistoryMaxRevisions != nil {
+		if *payload.HistoryMaxRevisions < 1 || *payload.HistoryMaxRevisions > 1000 {
+			return errors.New("historyMaxRevisions must be between 1 and 1000")
+		}
+	}
+	if payload.HistoryMaxAgeDays != nil && *payload.HistoryMaxAgeDays < 0 {
+		return errors.New("historyMaxAgeDays must not be negative")
+	}
 	if payload.DefaultFolder != nil {
 		cleaned, err := cleanRelPath(*payload.DefaultFolder)
 		if err != nil {
@@ -215,5 +260,15 @@ func validateSettingsPayload(payload SettingsPayload) error {
 		}
 		*payload.DailyFolder = cleaned
 	}
+	if payload.TaskInbox != nil {
+		cleaned, err := cleanRelPath(*payload.TaskInbox)
+		if err != nil {
+			return err
+		}
+		if cleaned == "" || cleaned == "." {
+			return errors.New("taskInbox must be a note path")
+		}
+		*payload.TaskInbox = filepath.ToSlash(ensureMarkdown(cleaned))
+	}
 	return nil
 }
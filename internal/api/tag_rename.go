@@ -0,0 +1,304 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var validTagPattern = regexp.MustCompile(`^` + tagBody + `$`)
+
+type TagRenamePayload struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	DryRun     bool              `json:"dryRun"`
+	BaseHashes map[string]string `json:"baseHashes"`
+}
+
+type TagRenameResponse struct {
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	DryRun       bool          `json:"dryRun"`
+	Files        []ReplaceFile `json:"files"`
+	Replacements int           `json:"replacements"`
+}
+
+func (s *Server) handleTagRename(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[TagRenamePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from, err := cleanTagName(payload.From, "from")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := cleanTagName(payload.To, "to")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if from == to {
+		writeError(w, http.StatusBadRequest, "from and to must differ")
+		return
+	}
+
+	var edits []replaceEdit
+	err = s.walkNotes(func(rel string, data []byte) error {
+		edit, ok := planTagRename(string(data), from, to)
+		if !ok {
+			return nil
+		}
+		edit.file.Path = rel
+		edit.file.Hash = noteHash(data)
+		edit.original = data
+		edits = append(edits, edit)
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to scan notes")
+		return
+	}
+
+	resp := TagRenameResponse{From: from, To: to, DryRun: payload.DryRun, Files: make([]ReplaceFile, 0, len(edits))}
+	for _, edit := range edits {
+		resp.Replacements += edit.file.Replacements
+	}
+	if payload.DryRun {
+		for _, edit := range edits {
+			resp.Files = append(resp.Files, edit.file)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if payload.BaseHashes != nil {
+		if changed := replaceConflicts(edits, payload.BaseHashes); len(changed) > 0 {
+			s.logger.Warn("tag rename conflict", "paths", changed)
+			writeJSON(w, http.StatusConflict, ReplaceConflictResponse{Error: "notes changed since the preview", Paths: changed})
+			return
+		}
+	}
+
+	changed, err := s.applyReplaceEdits(edits, "tags")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to update notes")
+		return
+	}
+	if len(changed) > 0 {
+		s.logger.Warn("tag rename conflict", "paths", changed)
+		writeJSON(w, http.StatusConflict, ReplaceConflictResponse{Error: "notes changed during the rename", Paths: changed})
+		return
+	}
+	for _, edit := range edits {
+		edit.file.Hash = noteHash([]byte(edit.content))
+		edit.file.Changes = nil
+		edit.file.Frontmatter = nil
+		resp.Files = append(resp.Files, edit.file)
+	}
+	s.logger.Info("tag renamed", "from", from, "to", to, "files", len(edits), "replacements", resp.Replacements)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func cleanTagName(raw, field string) (string, error) {
+	tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(raw), "#"), "/")
+	if tag == "" {
+		return "", errors.New(field + " is required")
+	}
+	if !validTagPattern.MatchString(tag) {
+		return "", errors.New(field + " must be letters, digits, -, _, and / between levels")
+	}
+	return tag, nil
+}
+
+// renameTag moves tag to to when it is from or nested below it, keeping the
+// nested levels: renaming work to job turns work/acme into job/acme. It
+// reports whether the tag changed.
+func renameTag(tag, from, to string) (string, bool) {
+	end, ok := tagPrefixFold(tag, from)
+	if !ok {
+		return tag, false
+	}
+	renamed := to + tag[end:]
+	return renamed, renamed != tag
+}
+
+// planTagRename rewrites the inline tags (task tags included) and the
+// frontmatter tags of a note. Inline tags in the frontmatter block are left
+// alone when it parses, since its tags key is handled separately, and so is
+// text in fenced code blocks and inline code.
+func planTagRename(content, from, to string) (replaceEdit, bool) {
+	var edit replaceEdit
+	block, body, hasFrontmatter := splitFrontmatter(content)
+	frontmatter, err := parseFrontmatter(content)
+	if err != nil || !hasFrontmatter {
+		frontmatter, body = nil, content
+	}
+	prefix := content[:len(content)-len(body)]
+	lineOffset := strings.Count(prefix, "\n")
+
+	lines := strings.Split(body, "\n")
+	var fence codeFence
+	for i, line := range lines {
+		raw := strings.TrimSuffix(line, "\r")
+		if fence.skip(raw) {
+			continue
+		}
+		updated, count := renameInlineTags(raw, from, to)
+		if count == 0 {
+			continue
+		}
+		edit.file.Replacements += count
+		edit.file.Changes = append(edit.file.Changes, ReplaceChange{LineNumber: lineOffset + i + 1, Before: raw, After: updated})
+		lines[i] = updated + line[len(raw):]
+	}
+	updated := prefix + strings.Join(lines, "\n")
+
+	if frontmatter != nil {
+		if before := frontmatterList(frontmatter, "tags"); len(before) > 0 {
+			hashed := hashedFrontmatterTags(frontmatter["tags"])
+			after := make([]string, 0, len(before))
+			written := make([]string, 0, len(before))
+			seen := make(map[string]bool, len(before))
+			count := 0
+			for _, tag := range before {
+				renamed, ok := renameTag(tag, from, to)
+				if ok {
+					count++
+				}
+				if !seen[renamed] {
+					seen[renamed] = true
+					after = append(after, renamed)
+					if hashed[tag] {
+						renamed = "#" + renamed
+					}
+					written = append(written, renamed)
+				}
+			}
+			if count > 0 {
+				var value any = written
+				if raw, ok := frontmatter["tags"].(string); ok {
+					separator := " "
+					if strings.Contains(raw, ",") {
+						separator = ", "
+					}
+					value = strings.Join(written, separator)
+				}
+				rewritten, err := updateFrontmatter(updated, map[string]any{"tags": value}, nil)
+				if err == nil {
+					updated = rewritten
+					edit.file.Replacements += count
+					edit.file.Frontmatter = &ReplaceChange{
+						LineNumber: frontmatterKeyLine(block, "tags"),
+						Before:     strings.Join(before, ", "),
+						After:      strings.Join(after, ", "),
+					}
+				}
+			}
+		}
+	}
+
+	if edit.file.Replacements == 0 {
+		return edit, false
+	}
+	edit.content = updated
+	return edit, true
+}
+
+// hashedFrontmatterTags returns the frontmatter tags written with a leading
+// #, such as "#work", so a rename can keep that form.
+func hashedFrontmatterTags(value any) map[string]bool {
+	var items []string
+	switch v := value.(type) {
+	case string:
+		items = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case []any:
+		for _, item := range v {
+			if item != nil {
+				items = append(items, fmt.Sprint(item))
+			}
+		}
+	}
+	hashed := make(map[string]bool)
+	for _, item := range items {
+		if tag, ok := strings.CutPrefix(strings.TrimSpace(item), "#"); ok {
+			hashed[tag] = true
+		}
+	}
+	return hashed
+}
+
+// renameInlineTags renames the matching #tags in line, outside inline code,
+// and returns how many changed. A renamed tag the line already has, as when
+// #mtg is merged into #meeting in "#mtg #meeting", is dropped along with the
+// space before it rather than repeated.
+func renameInlineTags(line, from, to string) (string, int) {
+	locs := tagPattern.FindAllStringSubmatchIndex(line, -1)
+	spans := inlineCodeSpans(line)
+	renamed := make([]string, len(locs))
+	changed := make([]bool, len(locs))
+	for i, loc := range locs {
+		renamed[i] = line[loc[4]:loc[5]]
+		if !inSpans(spans, loc[4]) {
+			renamed[i], changed[i] = renameTag(renamed[i], from, to)
+		}
+	}
+	// A renamed tag is dropped when the line already has its new name in a
+	// tag the rename leaves alone, or when an earlier tag written differently,
+	// such as #Mtg after #mtg, was already renamed to it. Repeats of the same
+	// spelling were in the note before and stay.
+	drop := make([]bool, len(locs))
+	for i, loc := range locs {
+		if !changed[i] {
+			continue
+		}
+		for j, other := range locs {
+			if j == i || !strings.EqualFold(renamed[j], renamed[i]) {
+				continue
+			}
+			if !changed[j] && !inSpans(spans, other[4]) {
+				drop[i] = true
+			} else if changed[j] && j < i && !drop[j] && line[other[4]:other[5]] != line[loc[4]:loc[5]] {
+				drop[i] = true
+			}
+		}
+	}
+
+	count := 0
+	// Replace from the end so earlier offsets stay valid.
+	for i := len(locs) - 1; i >= 0; i-- {
+		if !changed[i] {
+			continue
+		}
+		count++
+		start, end := locs[i][4], locs[i][5]
+		if !drop[i] {
+			line = line[:start] + renamed[i] + line[end:]
+			continue
+		}
+		// Drop the # and tag with the whitespace before it, or after it when
+		// the tag starts the line.
+		cut := locs[i][2]
+		if cut == start-1 {
+			for end < len(line) && (line[end] == ' ' || line[end] == '\t') {
+				end++
+			}
+		}
+		line = line[:cut] + line[end:]
+	}
+	return line, count
+}
+
+// frontmatterKeyLine returns the line number in the note of a top-level key
+// in the frontmatter block, counting the opening delimiter as line 1.
+func frontmatterKeyLine(block, key string) int {
+	for i, line := range strings.Split(block, "\n") {
+		if strings.HasPrefix(line, key+":") {
+			return i + 2
+		}
+	}
+	return 1
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tagBody is the text of a tag after the #: Unicode letters, digits, - and _,
+// with / separating nested levels (#work/client/acme). Notes and tasks share
+// it so a tag means the same thing everywhere.
+const tagBody = `[\p{L}\p{M}\p{N}_-]+(?:/[\p{L}\p{M}\p{N}_-]+)*`
+
+// tagPattern matches a #tag preceded by whitespace or the start of the text.
+var tagPattern = regexp.MustCompile(`(^|\s)#(` + tagBody + `)`)
+
+// TagGroup is one level of the tag tree. Tag is the full tag and Name its last
+// level; Notes are the notes carrying exactly this tag, and Count is the
+// number of notes carrying it or any tag nested below it.
+type TagGroup struct {
+	Tag      string         `json:"tag"`
+	Name     string         `json:"name"`
+	Count    int            `json:"count"`
+	Notes    []SearchResult `json:"notes"`
+	Children []TagGroup     `json:"children,omitempty"`
+}
+
+// findTags returns every tag in text, in order and with its original case,
+// including repeats. Tags in fenced code blocks and inline code are skipped,
+// as tag renames skip them.
+func findTags(text string) []string {
+	var tags []string
+	var fence codeFence
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if fence.skip(line) {
+			continue
+		}
+		spans := inlineCodeSpans(line)
+		for _, loc := range tagPattern.FindAllStringSubmatchIndex(line, -1) {
+			if !inSpans(spans, loc[4]) {
+				tags = append(tags, line[loc[4]:loc[5]])
+			}
+		}
+	}
+	return tags
+}
+
+// tagKey folds the case of tag so spellings that differ only in case, such as
+// #Work and #work, are the same tag. Each rune becomes the smallest rune it
+// case-folds to, the equivalence strings.EqualFold uses.
+func tagKey(tag string) string {
+	return strings.Map(foldRune, tag)
+}
+
+func foldRune(r rune) rune {
+	smallest := r
+	for folded := unicode.SimpleFold(r); folded != r; folded = unicode.SimpleFold(folded) {
+		if folded < smallest {
+			smallest = folded
+		}
+	}
+	return smallest
+}
+
+// tagMatchesFold reports whether tag is query or nested below it, ignoring
+// case, so tag:work matches #work/acme.
+func tagMatchesFold(tag, query string) bool {
+	_, ok := tagPrefixFold(tag, query)
+	return ok
+}
+
+// tagPrefixFold matches query against the start of tag like tagMatchesFold and
+// returns the byte length of the matched levels in tag. Case-folded runes can
+// differ in length (k and the Kelvin sign), so it is measured in tag rather
+// than taken from query.
+func tagPrefixFold(tag, query string) (int, bool) {
+	query = strings.Trim(query, "/")
+	end := 0
+	for _, want := range query {
+		if end >= len(tag) {
+			return 0, false
+		}
+		got, size := utf8.DecodeRuneInString(tag[end:])
+		if foldRune(got) != foldRune(want) {
+			return 0, false
+		}
+		end += size
+	}
+	if end < len(tag) && tag[end] != '/' {
+		return 0, false
+	}
+	return end, true
+}
+
+func tagsContainFold(tags []string, tag string) bool {
+	for _, candidate := range tags {
+		if tagMatchesFold(candidate, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	tagMap := make(map[string]map[string]string)
+	err := s.walkNotes(func(rel string, data []byte) error {
+		baseName := filepath.Base(rel)
+		for _, tag := range extractNoteTags(string(data)) {
+			if tagMap[tag] == nil {
+				tagMap[tag] = make(map[string]string)
+			}
+			tagMap[tag][rel] = baseName
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list tags")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buildTagTree(tagMap))
+}
+
+// tagTreeNode collects a tag level while the tree is built. Levels are keyed
+// by tagKey, so spellings that differ only in case share a node.
+type tagTreeNode struct {
+	spellings map[string]map[string]bool
+	notes     map[string]string
+	subtree   map[string]bool
+	children  map[string]*tagTreeNode
+}
+
+// buildTagTree nests the tags in tagMap (tag -> note path -> note name) by
+// their / levels, ignoring case. Parent levels exist even when no note uses
+// them directly.
+func buildTagTree(tagMap map[string]map[string]string) []TagGroup {
+	root := &tagTreeNode{children: make(map[string]*tagTreeNode)}
+	for tag, notes := range tagMap {
+		node := root
+		for _, part := range strings.Split(tag, "/") {
+			key := tagKey(part)
+			child, ok := node.children[key]
+			if !ok {
+				child = &tagTreeNode{
+					spellings: make(map[string]map[string]bool),
+					notes:     make(map[string]string),
+					subtree:   make(map[string]bool),
+					children:  make(map[string]*tagTreeNode),
+				}
+				node.children[key] = child
+			}
+			if child.spellings[part] == nil {
+				child.spellings[part] = make(map[string]bool)
+			}
+			for relPath := range notes {
+				child.subtree[relPath] = true
+				child.spellings[part][relPath] = true
+			}
+			node = child
+		}
+		for relPath, noteName := range notes {
+			node.notes[relPath] = noteName
+		}
+	}
+	return root.groups("")
+}
+
+func (n *tagTreeNode) name() string {
+	counts := make(map[string]int, len(n.spellings))
+	for spelling, notes := range n.spellings {
+		counts[spelling] = len(notes)
+	}
+	return tagSpelling(counts)
+}
+
+// tagSpelling picks how to show a tag written several ways (spelling -> number
+// of notes): the spelling most notes use, or the smallest on a tie so the
+// choice is stable.
+func tagSpelling(counts map[string]int) string {
+	best := ""
+	for spelling, count := range counts {
+		if best == "" || count > counts[best] || (count == counts[best] && spelling < best) {
+			best = spelling
+		}
+	}
+	return best
+}
+
+func (n *tagTreeNode) groups(parent string) []TagGroup {
+	children := make([]*tagTreeNode, 0, len(n.children))
+	names := make(map[*tagTreeNode]string, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+		names[child] = child.name()
+	}
+	sort.Slice(children, func(i, j int) bool {
+		a, b := strings.ToLower(names[children[i]]), strings.ToLower(names[children[j]])
+		if a == b {
+			return names[children[i]] < names[children[j]]
+		}
+		return a < b
+	})
+
+	groups := make([]TagGroup, 0, len(children))
+	for _, child := range children {
+		group := TagGroup{Tag: names[child], Name: names[child], Count: len(child.subtree)}
+		if parent != "" {
+			group.Tag = parent + "/" + group.Name
+		}
+		group.Notes = make([]SearchResult, 0, len(child.notes))
+		for relPath, noteName := range child.notes {
+			group.Notes = append(group.Notes, SearchResult{Path: relPath, Name: noteName})
+		}
+		sort.Slice(group.Notes, func(i, j int) bool {
+			nameA := strings.ToLower(group.Notes[i].Name)
+			nameB := strings.ToLower(group.Notes[j].Name)
+			if nameA == nameB {
+				return group.Notes[i].Path < group.Notes[j].Path
+			}
+			return nameA < nameB
+		})
+		if len(child.children) > 0 {
+			group.Children = child.groups(group.Tag)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
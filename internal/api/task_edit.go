@@ -0,0 +1,602 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	taskMarkerNamePattern = regexp.MustCompile(`^[A-Za-z]+$`)
+	headingPattern        = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+)
+
+type TaskCreatePayload struct {
+	Path       string   `json:"path,omitempty"`
+	Heading    string   `json:"heading,omitempty"`
+	Text       string   `json:"text"`
+	Project    string   `json:"project,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Mentions   []string `json:"mentions,omitempty"`
+	Due        string   `json:"due,omitempty"`
+	Priority   int      `json:"priority,omitempty"`
+	Recurrence string   `json:"recurrence,omitempty"`
+}
+
+type TaskUpdatePayload struct {
+	Path       string    `json:"path"`
+	LineNumber int       `json:"lineNumber"`
+	LineHash   string    `json:"lineHash"`
+	Text       *string   `json:"text,omitempty"`
+	Project    *string   `json:"project,omitempty"`
+	Tags       *[]string `json:"tags,omitempty"`
+	Mentions   *[]string `json:"mentions,omitempty"`
+	Due        *string   `json:"due,omitempty"`
+	Priority   *int      `json:"priority,omitempty"`
+	Recurrence *string   `json:"recurrence,omitempty"`
+	Completed  *bool     `json:"completed,omitempty"`
+}
+
+// taskFields are the parts of a task line that can be edited, written the
+// way they appear in the note (original case, no markers).
+type taskFields struct {
+	Text       string
+	Project    string
+	Tags       []string
+	Mentions   []string
+	Due        string
+	Priority   int
+	Recurrence string
+}
+
+func (s *Server) handleTaskCreate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[TaskCreatePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields := taskFields{
+		Text:       payload.Text,
+		Project:    payload.Project,
+		Tags:       payload.Tags,
+		Mentions:   payload.Mentions,
+		Due:        payload.Due,
+		Priority:   payload.Priority,
+		Recurrence: payload.Recurrence,
+	}
+	if err := fields.normalize(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pathParam := strings.TrimSpace(payload.Path)
+	useInbox := pathParam == ""
+	if useInbox {
+		settings, _, err := s.loadSettings()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to load settings")
+			return
+		}
+		pathParam = settings.TaskInbox
+	}
+	absPath, relPath, err := s.resolvePath(ensureMarkdown(pathParam))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	defer s.lockNote(relPath)()
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			writeError(w, http.StatusInternalServerError, "unable to read note")
+			return
+		}
+		if !useInbox {
+			writeError(w, http.StatusNotFound, "note not found")
+			return
+		}
+		// The inbox note is created on first use.
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to create parent folders")
+			return
+		}
+		data = nil
+	}
+
+	if s.rewritesRelativeDates() {
+		fields.resolveDue(dueDateBase(relPath))
+	}
+	line := fields.format("", " ")
+	updated, lineNumber := insertTaskLine(string(data), strings.TrimSpace(payload.Heading), line)
+	if data != nil {
+		s.snapshotNote(relPath, data, "task")
+	}
+	if err := writeFileAtomic(absPath, []byte(updated), 0o644); err != nil {
+		s.logger.Error("unable to add task", "path", relPath, "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to update note")
+		return
+	}
+	s.indexPath(relPath)
+
+	s.logger.Info("task created", "path", relPath, "line", lineNumber)
+	writeJSON(w, http.StatusCreated, taskItemAt(relPath, updated, lineNumber))
+}
+
+func (s *Server) handleTaskUpdate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[TaskUpdatePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	absPath, relPath, data, lines, lineIndex, unlock, ok := s.loadTaskLine(w, payload.Path, payload.LineNumber, payload.LineHash)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	raw := strings.TrimSuffix(lines[lineIndex], "\r")
+	lineEnding := lines[lineIndex][len(raw):]
+	_, marker, rest, _ := splitTaskLine(raw)
+	fields := parseTaskFields(rest)
+	if payload.Text != nil {
+		fields.Text = *payload.Text
+	}
+	if payload.Project != nil {
+		fields.Project = *payload.Project
+	}
+	if payload.Tags != nil {
+		fields.Tags = *payload.Tags
+	}
+	if payload.Mentions != nil {
+		fields.Mentions = *payload.Mentions
+	}
+	if payload.Due != nil {
+		fields.Due = *payload.Due
+	}
+	if payload.Priority != nil {
+		fields.Priority = *payload.Priority
+	}
+	if payload.Recurrence != nil {
+		fields.Recurrence = *payload.Recurrence
+	}
+	if err := fields.normalize(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	dueChanged := payload.Due != nil
+	if s.rewritesRelativeDates() {
+		due := fields.Due
+		fields.resolveDue(dueDateBase(relPath))
+		dueChanged = dueChanged || fields.Due != due
+	}
+
+	updatedLine := raw[:len(raw)-len(rest)] + editTaskText(rest, payload, fields, dueChanged)
+	wasCompleted := marker != " "
+	if payload.Completed != nil && *payload.Completed != wasCompleted {
+		updatedLine, _ = setTaskLineCompletion(updatedLine, *payload.Completed)
+	}
+	lines[lineIndex] = updatedLine + lineEnding
+	if payload.Completed != nil && *payload.Completed && !wasCompleted {
+		subtree := taskSubtree(parseTodoLines(string(data), dueDateBase(relPath)), lineIndex+1)
+		lines, _, _ = insertNextRecurring(lines, lineIndex, subtree, dueDateBase(relPath))
+	}
+	updated := strings.Join(lines, "\n")
+	s.snapshotNote(relPath, data, "task")
+	if err := writeFileAtomic(absPath, []byte(updated), 0o644); err != nil {
+		s.logger.Error("unable to update task", "path", relPath, "line", lineIndex+1, "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to update note")
+		return
+	}
+	s.indexPath(relPath)
+
+	s.logger.Info("task updated", "path", relPath, "line", lineIndex+1)
+	writeJSON(w, http.StatusOK, taskItemAt(relPath, updated, lineIndex+1))
+}
+
+func (s *Server) handleTaskDelete(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lineNumber, err := strconv.Atoi(strings.TrimSpace(query.Get("lineNumber")))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "lineNumber must be positive")
+		return
+	}
+	cascade := false
+	if raw := strings.TrimSpace(query.Get("cascade")); raw != "" {
+		cascade, err = strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "cascade must be true or false")
+			return
+		}
+	}
+	absPath, relPath, data, lines, lineIndex, unlock, ok := s.loadTaskLine(w, query.Get("path"), lineNumber, query.Get("lineHash"))
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	// Removing only the parent would hand its subtasks to another task, so
+	// they go with it, and only when the request asks for that.
+	subtree := taskSubtree(parseTodoLines(string(data), dueDateBase(relPath)), lineIndex+1)
+	if len(subtree) > 0 && !cascade {
+		writeError(w, http.StatusConflict, "task has subtasks; set cascade to delete them too")
+		return
+	}
+	for i := len(subtree) - 1; i >= 0; i-- {
+		lines = append(lines[:subtree[i]-1], lines[subtree[i]:]...)
+	}
+	lines = append(lines[:lineIndex], lines[lineIndex+1:]...)
+	updated := strings.Join(lines, "\n")
+	s.snapshotNote(relPath, data, "task")
+	if err := writeFileAtomic(absPath, []byte(updated), 0o644); err != nil {
+		s.logger.Error("unable to delete task", "path", relPath, "line", lineIndex+1, "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to update note")
+		return
+	}
+	s.indexPath(relPath)
+
+	s.logger.Info("task deleted", "path", relPath, "line", lineIndex+1, "subtasks", len(subtree))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// loadTaskLine reads the note holding a task and finds its line with the same
+// lineHash check as /tasks/toggle. It writes the error response itself and
+// reports false when the task cannot be used; otherwise the note stays locked
+// until the returned unlock is called.
+func (s *Server) loadTaskLine(w http.ResponseWriter, pathParam string, lineNumber int, lineHash string) (string, string, []byte, []string, int, func(), bool) {
+	if strings.TrimSpace(pathParam) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return "", "", nil, nil, 0, nil, false
+	}
+	if lineNumber <= 0 {
+		writeError(w, http.StatusBadRequest, "lineNumber must be positive")
+		return "", "", nil, nil, 0, nil, false
+	}
+	absPath, relPath, err := s.resolvePath(pathParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", "", nil, nil, 0, nil, false
+	}
+	if !isMarkdown(absPath) {
+		writeError(w, http.StatusBadRequest, "not a note file")
+		return "", "", nil, nil, 0, nil, false
+	}
+	unlock := s.lockNote(relPath)
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		unlock()
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "note not found")
+			return "", "", nil, nil, 0, nil, false
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return "", "", nil, nil, 0, nil, false
+	}
+
+	lines := strings.Split(string(data), "\n")
+	lineIndex, ok := findTaskLine(lines, lineNumber, lineHash)
+	if !ok {
+		unlock()
+		writeError(w, http.StatusBadRequest, "task not found")
+		return "", "", nil, nil, 0, nil, false
+	}
+	if _, _, _, ok := splitTaskLine(strings.TrimSuffix(lines[lineIndex], "\r")); !ok {
+		unlock()
+		writeError(w, http.StatusBadRequest, "line is not a task")
+		return "", "", nil, nil, 0, nil, false
+	}
+	return absPath, relPath, data, lines, lineIndex, unlock, true
+}
+
+// splitTaskLine splits a task line into its indentation, checkbox marker, and
+// the text after the checkbox.
+func splitTaskLine(line string) (string, string, string, bool) {
+	match := todoTogglePattern.FindStringSubmatchIndex(line)
+	if match == nil {
+		return "", "", "", false
+	}
+	indent := line[:strings.IndexByte(line, '-')]
+	return indent, line[match[4]:match[5]], line[match[1]:], true
+}
+
+// parseTaskFields reads the editable fields of a task line's text, keeping
+// their case.
+func parseTaskFields(rest string) taskFields {
+	fields := taskFields{
+		Text:       cleanTaskText(rest),
+		Project:    extractFirstMatch(taskProjectPattern, rest),
+		Due:        extractDueDate(rest),
+		Priority:   extractPriority(rest),
+		Recurrence: extractFirstMatch(taskRecurrencePattern, rest),
+	}
+	for _, tag := range findTags(rest) {
+		if !containsString(fields.Tags, tag) {
+			fields.Tags = append(fields.Tags, tag)
+		}
+	}
+	for _, match := range taskMentionPattern.FindAllStringSubmatch(rest, -1) {
+		if !containsString(fields.Mentions, match[2]) {
+			fields.Mentions = append(fields.Mentions, match[2])
+		}
+	}
+	return fields
+}
+
+// editTaskText applies the fields named in payload to the text after a task's
+// checkbox. Markers are edited where they are; everything else in the line
+// stays as the user wrote it. A new text keeps the existing markers, in their
+// order, after it.
+func editTaskText(rest string, payload TaskUpdatePayload, fields taskFields, dueChanged bool) string {
+	if payload.Text != nil {
+		parts := []string{fields.Text}
+		for _, match := range taskTokenPattern.FindAllStringSubmatch(rest, -1) {
+			parts = append(parts, match[2])
+		}
+		rest = strings.Join(parts, " ")
+	}
+	if payload.Project != nil {
+		rest = setTaskMarker(rest, taskProjectPattern, "+", fields.Project)
+	}
+	if payload.Tags != nil {
+		rest = setTaskMarkers(rest, tagPattern, "#", fields.Tags)
+	}
+	if payload.Mentions != nil {
+		rest = setTaskMarkers(rest, taskMentionPattern, "@", fields.Mentions)
+	}
+	if dueChanged {
+		rest = setTaskMarker(rest, taskDuePattern, ">", fields.Due)
+	}
+	if payload.Priority != nil {
+		priority := ""
+		if fields.Priority > 0 {
+			priority = strconv.Itoa(fields.Priority)
+		}
+		rest = setTaskMarker(rest, taskPriorityPattern, "^", priority)
+	}
+	if payload.Recurrence != nil {
+		rest = setTaskMarker(rest, taskRecurrencePattern, "*every:", fields.Recurrence)
+	}
+	return strings.TrimLeft(rest, " \t")
+}
+
+// setTaskMarker sets the value of the first marker pattern matches in text.
+// An empty value removes the marker; a missing marker is appended.
+func setTaskMarker(text string, pattern *regexp.Regexp, prefix, value string) string {
+	match := pattern.FindStringSubmatchIndex(text)
+	if match == nil {
+		if value == "" {
+			return text
+		}
+		return strings.TrimRight(text, " \t") + " " + prefix + value
+	}
+	end := match[5]
+	if pattern == taskDuePattern {
+		// Punctuation after a due date is not part of it.
+		end = match[4] + len(extractDueDate(text[match[0]:]))
+	}
+	if value == "" {
+		return text[:match[0]] + text[end:]
+	}
+	return text[:match[4]] + value + text[end:]
+}
+
+// setTaskMarkers makes the markers pattern matches in text equal values,
+// ignoring case: markers in values stay where they are, the others are
+// removed, and new ones are appended.
+func setTaskMarkers(text string, pattern *regexp.Regexp, prefix string, values []string) string {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	kept := make([]bool, len(values))
+	keep := make([]int, len(matches))
+	for i, match := range matches {
+		keep[i] = -1
+		for j, value := range values {
+			if !kept[j] && strings.EqualFold(text[match[4]:match[5]], value) {
+				kept[j], keep[i] = true, j
+				break
+			}
+		}
+	}
+	// Edit from the end so earlier offsets stay valid.
+	for i := len(matches) - 1; i >= 0; i-- {
+		match := matches[i]
+		if keep[i] < 0 {
+			text = text[:match[0]] + text[match[5]:]
+		} else {
+			text = text[:match[4]] + values[keep[i]] + text[match[5]:]
+		}
+	}
+	for j, value := range values {
+		if !kept[j] {
+			text = strings.TrimRight(text, " \t") + " " + prefix + value
+		}
+	}
+	return text
+}
+
+// normalize trims the fields, drops marker characters the client may have
+// included, and checks that each one reads back as the same field.
+func (f *taskFields) normalize() error {
+	f.Text = strings.TrimSpace(f.Text)
+	if f.Text == "" {
+		return errors.New("text is required")
+	}
+	if strings.ContainsAny(f.Text, "\r\n") {
+		return errors.New("text must be a single line")
+	}
+	f.Project = strings.TrimPrefix(strings.TrimSpace(f.Project), "+")
+	if f.Project != "" && !taskMarkerNamePattern.MatchString(f.Project) {
+		return errors.New("project must be letters only")
+	}
+	for i, tag := range f.Tags {
+		f.Tags[i] = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "#"), "/")
+		if !validTagPattern.MatchString(f.Tags[i]) {
+			return errors.New("tags must be letters, digits, -, _, and / between levels")
+		}
+	}
+	for i, mention := range f.Mentions {
+		f.Mentions[i] = strings.TrimPrefix(strings.TrimSpace(mention), "@")
+		if !taskMarkerNamePattern.MatchString(f.Mentions[i]) {
+			return errors.New("mentions must be letters only")
+		}
+	}
+	f.Tags = uniqueFold(f.Tags)
+	f.Mentions = uniqueFold(f.Mentions)
+	f.Due = strings.TrimPrefix(strings.TrimSpace(f.Due), ">")
+	if strings.ContainsAny(f.Due, " \t") {
+		return errors.New("due must not contain spaces")
+	}
+	f.Recurrence = strings.TrimPrefix(strings.TrimSpace(f.Recurrence), "*every:")
+	if _, ok := parseRecurrence(f.Recurrence); f.Recurrence != "" && !ok {
+		return errors.New("recurrence must be a rule like week, 2d, week:fri, or month:15")
+	}
+	if f.Priority < 0 || f.Priority > 5 {
+		return errors.New("priority must be between 1 and 5, or 0 for none")
+	}
+	return nil
+}
+
+// uniqueFold drops values that repeat an earlier one, ignoring case.
+func uniqueFold(values []string) []string {
+	unique := values[:0]
+	for _, value := range values {
+		if !containsFold(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDue replaces a relative due date with its ISO date.
+func (f *taskFields) resolveDue(base time.Time) {
+	if resolved, ok := resolveRelativeDate(f.Due, base); ok {
+		f.Due = resolved.Format("2006-01-02")
+	}
+}
+
+// format writes the task line with its markers in the order the README
+// example uses (+project #tags @mentions >due ^priority), with a recurrence
+// rule after the due date.
+func (f taskFields) format(indent, marker string) string {
+	parts := []string{indent + "- [" + marker + "]", f.Text}
+	if f.Project != "" {
+		parts = append(parts, "+"+f.Project)
+	}
+	for _, tag := range f.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	for _, mention := range f.Mentions {
+		parts = append(parts, "@"+mention)
+	}
+	if f.Due != "" {
+		parts = append(parts, ">"+f.Due)
+	}
+	if f.Recurrence != "" {
+		parts = append(parts, "*every:"+f.Recurrence)
+	}
+	if f.Priority > 0 {
+		parts = append(parts, "^"+strconv.Itoa(f.Priority))
+	}
+	return strings.Join(parts, " ")
+}
+
+// insertTaskLine adds line to content and returns the new content and the
+// line's number. With a heading, the task goes at the end of that section,
+// which is appended to the note when missing; otherwise it goes at the end
+// of the note.
+func insertTaskLine(content, heading, line string) (string, int) {
+	lineEnding := ""
+	if strings.Contains(content, "\r\n") {
+		lineEnding = "\r"
+	}
+	lines := strings.Split(content, "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	if heading != "" {
+		for i, candidate := range lines {
+			level, title := parseHeading(candidate)
+			if level == 0 || !strings.EqualFold(title, heading) {
+				continue
+			}
+			end := len(lines)
+			for j := i + 1; j < len(lines); j++ {
+				if next, _ := parseHeading(lines[j]); next > 0 && next <= level {
+					end = j
+					break
+				}
+			}
+			for end > i+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+			lines = insertLine(lines, end, line)
+			return strings.Join(lines, "\n"), end + 1
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if heading != "" {
+		if len(lines) > 0 {
+			lines = append(lines, lineEnding)
+		}
+		lines = append(lines, "## "+heading+lineEnding)
+	}
+	lines = append(lines, line+lineEnding)
+	lineNumber := len(lines)
+	lines = append(lines, "")
+	return strings.Join(lines, "\n"), lineNumber
+}
+
+// insertLine inserts line before lines[index], or at the end when index is
+// len(lines), using the note's CRLF line endings when it has them.
+func insertLine(lines []string, index int, line string) []string {
+	crlf := false
+	for _, existing := range lines {
+		if strings.HasSuffix(existing, "\r") {
+			crlf = true
+			break
+		}
+	}
+	if crlf {
+		if index == len(lines) {
+			lines[index-1] += "\r"
+		} else {
+			line += "\r"
+		}
+	}
+	return append(lines[:index], append([]string{line}, lines[index:]...)...)
+}
+
+func parseHeading(line string) (int, string) {
+	match := headingPattern.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+	if match == nil {
+		return 0, ""
+	}
+	return len(match[1]), match[2]
+}
+
+// taskItemAt parses the task on lineNumber of content.
+func taskItemAt(rel, content string, lineNumber int) TaskItem {
+	for _, item := range taskItemsFromTodos(rel, parseTodoLines(content, dueDateBase(rel))) {
+		if item.LineNumber == lineNumber {
+			return item
+		}
+	}
+	return TaskItem{Path: rel, LineNumber: lineNumber}
+}
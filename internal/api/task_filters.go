@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxTaskLimit = 1000
+
+// taskFilter holds the GET /tasks query parameters. Empty fields match
+// every task.
+type taskFilter struct {
+	project     string
+	tag         string
+	mention     string
+	completed   *bool
+	minPriority int
+	maxPriority int
+	dueBefore   string
+	dueAfter    string
+	due         string
+	path        string
+	words       []string
+	sort        string
+	limit       int
+	offset      int
+}
+
+func (s *Server) parseTaskFilter(query url.Values) (taskFilter, error) {
+	filter := taskFilter{
+		project: strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query.Get("project")), "+")),
+		tag:     strings.Trim(strings.TrimPrefix(strings.TrimSpace(query.Get("tag")), "#"), "/"),
+		mention: strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query.Get("mention")), "@")),
+		words:   strings.Fields(strings.ToLower(query.Get("query"))),
+	}
+
+	if raw := strings.TrimSpace(query.Get("completed")); raw != "" {
+		completed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, errors.New("completed must be true or false")
+		}
+		filter.completed = &completed
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{
+		{"minPriority", &filter.minPriority},
+		{"maxPriority", &filter.maxPriority},
+	} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 5 {
+			return filter, errors.New(param.name + " must be between 1 and 5")
+		}
+		*param.value = parsed
+	}
+	for _, param := range []struct {
+		name  string
+		value *string
+	}{
+		{"dueBefore", &filter.dueBefore},
+		{"dueAfter", &filter.dueAfter},
+	} {
+		raw := strings.TrimSpace(query.Get(param.name))
+		if raw == "" {
+			continue
+		}
+		if _, err := time.ParseInLocation("2006-01-02", raw, time.Local); err != nil {
+			return filter, errors.New(param.name + " must be a date like 2025-01-31")
+		}
+		*param.value = raw
+	}
+	switch filter.due = strings.TrimSpace(query.Get("due")); filter.due {
+	case "", "overdue", "today", "week", "none":
+	default:
+		return filter, errors.New("due must be overdue, today, week, or none")
+	}
+	if raw := strings.TrimSpace(query.Get("path")); raw != "" {
+		_, relPath, err := s.resolvePath(raw)
+		if err != nil {
+			return filter, err
+		}
+		filter.path = relPath
+	}
+	switch filter.sort = strings.TrimSpace(query.Get("sort")); filter.sort {
+	case "", "due", "priority", "path", "project":
+	default:
+		return filter, errors.New("sort must be due, priority, path, or project")
+	}
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxTaskLimit {
+			return filter, errors.New("limit must be between 1 and 1000")
+		}
+		filter.limit = parsed
+	}
+	if raw := strings.TrimSpace(query.Get("offset")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return filter, errors.New("offset must not be negative")
+		}
+		filter.offset = parsed
+	}
+	return filter, nil
+}
+
+// apply filters, sorts, and pages tasks. It returns the page and the number
+// of tasks that matched before paging.
+func (f taskFilter) apply(tasks []TaskItem) ([]TaskItem, int) {
+	today := timeNow().Format("2006-01-02")
+	weekEnd := timeNow().AddDate(0, 0, 6).Format("2006-01-02")
+	matched := make([]TaskItem, 0, len(tasks))
+	for _, task := range tasks {
+		if f.matches(task, today, weekEnd) {
+			matched = append(matched, task)
+		}
+	}
+	if f.sort != "" {
+		sortTasks(matched, f.sort)
+	}
+
+	total := len(matched)
+	if f.offset >= total {
+		return []TaskItem{}, total
+	}
+	matched = matched[f.offset:]
+	if f.limit > 0 && len(matched) > f.limit {
+		matched = matched[:f.limit]
+	}
+	return matched, total
+}
+
+func (f taskFilter) matches(task TaskItem, today, weekEnd string) bool {
+	if f.project != "" && task.Project != f.project {
+		return false
+	}
+	if f.tag != "" && !tagsContainFold(task.Tags, f.tag) {
+		return false
+	}
+	if f.mention != "" && !containsString(task.Mentions, f.mention) {
+		return false
+	}
+	if f.completed != nil && task.Completed != *f.completed {
+		return false
+	}
+	if f.minPriority > 0 && (task.Priority == 0 || task.Priority < f.minPriority) {
+		return false
+	}
+	if f.maxPriority > 0 && (task.Priority == 0 || task.Priority > f.maxPriority) {
+		return false
+	}
+	due := task.DueDateISO
+	if (f.dueBefore != "" || f.dueAfter != "") && due == "" {
+		return false
+	}
+	if f.dueBefore != "" && due > f.dueBefore {
+		return false
+	}
+	if f.dueAfter != "" && due < f.dueAfter {
+		return false
+	}
+	switch f.due {
+	case "overdue":
+		if due == "" || due >= today || task.Completed {
+			return false
+		}
+	case "today":
+		if due != today {
+			return false
+		}
+	case "week":
+		if due == "" || due < today || due > weekEnd {
+			return false
+		}
+	case "none":
+		if due != "" {
+			return false
+		}
+	}
+	if f.path != "" && task.Path != f.path && !strings.HasPrefix(task.Path, f.path+"/") {
+		return false
+	}
+	if len(f.words) > 0 {
+		text := strings.ToLower(task.Text)
+		for _, word := range f.words {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortTasks orders tasks by key, falling back to note order (path, then
+// line) for ties. Tasks without a due date, priority, or project sort after
+// the ones that have one.
+func sortTasks(tasks []TaskItem, key string) {
+	byPath := func(a, b TaskItem) int {
+		if a.Path != b.Path {
+			return strings.Compare(a.Path, b.Path)
+		}
+		return a.LineNumber - b.LineNumber
+	}
+	byDue := func(a, b TaskItem) int {
+		switch {
+		case a.DueDateISO == b.DueDateISO:
+			return 0
+		case a.DueDateISO == "":
+			return 1
+		case b.DueDateISO == "":
+			return -1
+		}
+		return strings.Compare(a.DueDateISO, b.DueDateISO)
+	}
+	byPriority := func(a, b TaskItem) int {
+		switch {
+		case a.Priority == b.Priority:
+			return 0
+		case a.Priority == 0:
+			return 1
+		case b.Priority == 0:
+			return -1
+		}
+		return a.Priority - b.Priority
+	}
+	byProject := func(a, b TaskItem) int {
+		switch {
+		case a.Project == b.Project:
+			return 0
+		case a.Project == "":
+			return 1
+		case b.Project == "":
+			return -1
+		}
+		return strings.Compare(a.Project, b.Project)
+	}
+
+	var order []func(a, b TaskItem) int
+	switch key {
+	case "due":
+		order = []func(a, b TaskItem) int{byDue, byPriority}
+	case "priority":
+		order = []func(a, b TaskItem) int{byPriority, byDue}
+	case "project":
+		order = []func(a, b TaskItem) int{byProject, byDue, byPriority}
+	}
+	order = append(order, byPath)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, compare := range order {
+			if c := compare(tasks[i], tasks[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
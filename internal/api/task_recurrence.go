@@ -0,0 +1,187 @@
+package api
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	taskRecurrencePattern = regexp.MustCompile(`(^|\s)\*every:(\S+)`)
+	recurrenceStepPattern = regexp.MustCompile(`^(\d{1,3})([dwmy])$`)
+)
+
+var recurrenceUnits = map[string]string{
+	"day": "d", "daily": "d",
+	"week": "w", "weekly": "w",
+	"month": "m", "monthly": "m",
+	"year": "y", "yearly": "y",
+}
+
+// taskRecurrence is a parsed *every: rule: every count units, optionally on a
+// weekday (week rules) or day of the month (month rules).
+type taskRecurrence struct {
+	count      int
+	unit       string
+	monthDay   int
+	weekday    time.Weekday
+	hasWeekday bool
+}
+
+// parseRecurrence reads the rule after *every:, such as week, 2d, 3w,
+// month:15, or week:fri.
+func parseRecurrence(rule string) (taskRecurrence, bool) {
+	parts := strings.Split(strings.ToLower(rule), ":")
+	if len(parts) > 2 {
+		return taskRecurrence{}, false
+	}
+	rec := taskRecurrence{count: 1}
+	if unit, ok := recurrenceUnits[parts[0]]; ok {
+		rec.unit = unit
+	} else {
+		match := recurrenceStepPattern.FindStringSubmatch(parts[0])
+		if match == nil {
+			return taskRecurrence{}, false
+		}
+		count, err := strconv.Atoi(match[1])
+		if err != nil || count < 1 {
+			return taskRecurrence{}, false
+		}
+		rec.count, rec.unit = count, match[2]
+	}
+	if len(parts) == 1 {
+		return rec, true
+	}
+	switch rec.unit {
+	case "w":
+		weekday, ok := weekdayNames[parts[1]]
+		if !ok {
+			return taskRecurrence{}, false
+		}
+		rec.weekday, rec.hasWeekday = weekday, true
+	case "m":
+		day, err := strconv.Atoi(parts[1])
+		if err != nil || day < 1 || day > 31 {
+			return taskRecurrence{}, false
+		}
+		rec.monthDay = day
+	default:
+		return taskRecurrence{}, false
+	}
+	return rec, true
+}
+
+// next returns the first occurrence after from.
+func (rec taskRecurrence) next(from time.Time) time.Time {
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	switch rec.unit {
+	case "d":
+		return day.AddDate(0, 0, rec.count)
+	case "w":
+		if rec.hasWeekday {
+			return nextWeekday(day, rec.weekday).AddDate(0, 0, 7*(rec.count-1))
+		}
+		return day.AddDate(0, 0, 7*rec.count)
+	case "m":
+		if rec.monthDay == 0 {
+			return addMonths(day, rec.count)
+		}
+		// A day still ahead in the current month comes first.
+		first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+		if candidate := onMonthDay(first, rec.monthDay); candidate.After(day) {
+			return candidate
+		}
+		return onMonthDay(first.AddDate(0, rec.count, 0), rec.monthDay)
+	default:
+		return addMonths(day, 12*rec.count)
+	}
+}
+
+// onMonthDay returns monthDay in the month starting at first, stopping at the
+// last day of the month.
+func onMonthDay(first time.Time, monthDay int) time.Time {
+	return first.AddDate(0, 0, min(monthDay, first.AddDate(0, 1, -1).Day())-1)
+}
+
+// nextRecurringTask returns an open copy of a recurring task line, due on the
+// rule's next date. The next date counts from the task's due date, or from
+// today when it has none. base resolves relative due dates, as in
+// parseTodoLines.
+func nextRecurringTask(line string, base time.Time) (string, bool) {
+	todos := parseTodoLines(line, base)
+	if len(todos) != 1 || todos[0].Recurrence == "" {
+		return "", false
+	}
+	rule, ok := parseRecurrence(todos[0].Recurrence)
+	if !ok {
+		return "", false
+	}
+	from := timeNow()
+	if todos[0].DueDateValid {
+		if due, err := time.ParseInLocation("2006-01-02", todos[0].DueDateISO, time.Local); err == nil {
+			from = due
+		}
+	}
+	reopened, ok := setTaskLineCompletion(line, false)
+	if !ok {
+		return "", false
+	}
+	return setTaskLineDue(reopened, rule.next(from).Format("2006-01-02")), true
+}
+
+// insertNextRecurring adds the next occurrence of a completed recurring task
+// below it and its subtasks, followed by an open copy of each subtask so the
+// checklist repeats with it. lines is the note with the task at lineIndex;
+// subtree lists the subtask line numbers. It returns the index of the new
+// occurrence, or false when the task does not recur or its next occurrence
+// already follows the subtree, as after completing, reopening and completing
+// the task again.
+func insertNextRecurring(lines []string, lineIndex int, subtree []int, base time.Time) ([]string, int, bool) {
+	next, ok := nextRecurringTask(strings.TrimSuffix(lines[lineIndex], "\r"), base)
+	if !ok {
+		return lines, 0, false
+	}
+	nextIndex := lineIndex + 1
+	if len(subtree) > 0 {
+		nextIndex = subtree[len(subtree)-1]
+	}
+	if nextIndex < len(lines) && isSameOpenTask(strings.TrimSuffix(lines[nextIndex], "\r"), next, base) {
+		return lines, 0, false
+	}
+	block := []string{next}
+	for _, lineNumber := range subtree {
+		if child, ok := setTaskLineCompletion(strings.TrimSuffix(lines[lineNumber-1], "\r"), false); ok {
+			block = append(block, child)
+		}
+	}
+	for i, line := range block {
+		lines = insertLine(lines, nextIndex+i, line)
+	}
+	return lines, nextIndex, true
+}
+
+// isSameOpenTask reports whether line is an open task with the text and due
+// date of want.
+func isSameOpenTask(line, want string, base time.Time) bool {
+	got, wanted := parseTodoLines(line, base), parseTodoLines(want, base)
+	if len(got) != 1 || len(wanted) != 1 || got[0].Completed {
+		return false
+	}
+	return got[0].Text == wanted[0].Text && got[0].DueDateISO == wanted[0].DueDateISO
+}
+
+// setTaskLineDue replaces the due date of a task line, or appends one.
+func setTaskLineDue(line, due string) string {
+	loc := todoLinePattern.FindStringIndex(line)
+	if loc == nil {
+		return line
+	}
+	rest := line[loc[1]:]
+	match := taskDuePattern.FindStringSubmatchIndex(rest)
+	if match == nil {
+		return strings.TrimRight(line, " \t") + " >" + due
+	}
+	start := loc[1] + match[4]
+	return line[:start] + due + line[start+len(extractDueDate(rest)):]
+}
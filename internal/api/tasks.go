@@ -9,22 +9,33 @@ import (
 )
 
 type TaskItem struct {
-	ID         string   `json:"id"`
-	Path       string   `json:"path"`
-	LineNumber int      `json:"lineNumber"`
-	LineHash   string   `json:"lineHash"`
-	Text       string   `json:"text"`
-	Completed  bool     `json:"completed"`
-	Project    string   `json:"project"`
-	Tags       []string `json:"tags"`
-	Mentions   []string `json:"mentions"`
-	DueDate    string   `json:"dueDate,omitempty"`
-	DueDateISO string   `json:"dueDateISO,omitempty"`
-	Priority   int      `json:"priority,omitempty"`
+	ID         string        `json:"id"`
+	Path       string        `json:"path"`
+	LineNumber int           `json:"lineNumber"`
+	LineHash   string        `json:"lineHash"`
+	Text       string        `json:"text"`
+	Completed  bool          `json:"completed"`
+	Project    string        `json:"project"`
+	Tags       []string      `json:"tags"`
+	Mentions   []string      `json:"mentions"`
+	DueDate    string        `json:"dueDate,omitempty"`
+	DueDateISO string        `json:"dueDateISO,omitempty"`
+	Priority   int           `json:"priority,omitempty"`
+	Recurrence string        `json:"recurrence,omitempty"`
+	ParentID   string        `json:"parentId,omitempty"`
+	Children   []string      `json:"children,omitempty"`
+	Progress   *TaskProgress `json:"progress,omitempty"`
+}
+
+// TaskProgress counts the tasks nested below a parent task, at any depth.
+type TaskProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
 }
 
 type TaskListResponse struct {
 	Tasks  []TaskItem `json:"tasks"`
+	Total  int        `json:"total"`
 	Notice string     `json:"notice,omitempty"`
 }
 
@@ -33,6 +44,7 @@ type TaskTogglePayload struct {
 	LineNumber int    `json:"lineNumber"`
 	LineHash   string `json:"lineHash"`
 	Completed  bool   `json:"completed"`
+	Cascade    bool   `json:"cascade"`
 }
 
 type TaskArchiveResponse struct {
@@ -41,13 +53,19 @@ type TaskArchiveResponse struct {
 }
 
 func (s *Server) handleTasksList(w http.ResponseWriter, r *http.Request) {
+	filter, err := s.parseTaskFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	tasks, notice, err := s.listTasks()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to load tasks")
 		return
 	}
 
-	resp := TaskListResponse{Tasks: tasks}
+	page, total := filter.apply(tasks)
+	resp := TaskListResponse{Tasks: page, Total: total}
 	if notice != "" {
 		resp.Notice = notice
 	}
@@ -80,6 +98,7 @@ func (s *Server) handleTasksToggle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	defer s.lockNote(relPath)()
 	data, err := os.ReadFile(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -91,24 +110,10 @@ func (s *Server) handleTasksToggle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	lines := strings.Split(string(data), "\n")
-	lineIndex := payload.LineNumber - 1
-	if lineIndex < 0 || lineIndex >= len(lines) || !lineHashMatches(lines[lineIndex], payload.LineHash) {
-		if payload.LineHash == "" {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
-		}
-		found := false
-		for i, line := range lines {
-			if lineHashMatches(line, payload.LineHash) {
-				lineIndex = i
-				found = true
-				break
-			}
-		}
-		if !found {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
-		}
+	lineIndex, ok := findTaskLine(lines, payload.LineNumber, payload.LineHash)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "task not found")
+		return
 	}
 
 	originalLine := lines[lineIndex]
@@ -125,15 +130,42 @@ func (s *Server) handleTasksToggle(w http.ResponseWriter, r *http.Request) {
 	}
 	lines[lineIndex] = updatedLine + lineEnding
 
+	subtree := taskSubtree(parseTodoLines(string(data), dueDateBase(relPath)), lineIndex+1)
+	cascaded := 0
+	if payload.Cascade {
+		for _, lineNumber := range subtree {
+			raw := strings.TrimSuffix(lines[lineNumber-1], "\r")
+			if child, ok := setTaskLineCompletion(raw, payload.Completed); ok && child != raw {
+				lines[lineNumber-1] = child + lines[lineNumber-1][len(raw):]
+				cascaded++
+			}
+		}
+	}
+
+	nextIndex := 0
+	if payload.Completed && !todoCompletedPattern.MatchString(originalLine) {
+		lines, nextIndex, _ = insertNextRecurring(lines, lineIndex, subtree, dueDateBase(relPath))
+	}
+
 	updated := strings.Join(lines, "\n")
-	if err := os.WriteFile(absPath, []byte(updated), 0o644); err != nil {
+	s.snapshotNote(relPath, data, "toggle")
+	if err := writeFileAtomic(absPath, []byte(updated), 0o644); err != nil {
 		s.logger.Error("unable to update task line", "path", relPath, "line", lineIndex+1, "error", err)
 		writeError(w, http.StatusInternalServerError, "unable to update note")
 		return
 	}
+	s.indexPath(relPath)
 
-	s.logger.Info("task toggled", "path", relPath, "line", lineIndex+1, "completed", payload.Completed)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	s.logger.Info("task toggled", "path", relPath, "line", lineIndex+1, "completed", payload.Completed, "cascaded", cascaded)
+	resp := map[string]any{"status": "updated"}
+	if payload.Cascade {
+		resp["cascaded"] = cascaded
+	}
+	if nextIndex > 0 {
+		resp["next"] = taskItemAt(relPath, updated, nextIndex+1)
+		s.logger.Info("recurring task added", "path", relPath, "line", nextIndex+1)
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleTasksArchive(w http.ResponseWriter, r *http.Request) {
@@ -154,6 +186,9 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 			return err
 		}
 		if d.IsDir() {
+			if s.isReservedDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if isIgnoredFile(d.Name()) || !isMarkdown(d.Name()) {
@@ -170,28 +205,14 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 		if err != nil {
 			return nil
 		}
-		parsed := parseTodoLines(string(data))
+		parsed := parseTodoLines(string(data), dueDateBase(rel))
 		for _, todo := range parsed {
-			task := TaskItem{
-				ID:         fmt.Sprintf("%s:%d", rel, todo.LineNumber),
-				Path:       rel,
-				LineNumber: todo.LineNumber,
-				LineHash:   todo.LineHash,
-				Text:       todo.Text,
-				Completed:  todo.Completed,
-				Project:    todo.Project,
-				Tags:       todo.Tags,
-				Mentions:   todo.Mentions,
-				DueDate:    todo.DueDateRaw,
-				DueDateISO: todo.DueDateISO,
-				Priority:   todo.Priority,
-			}
 			if todo.DueDateRaw != "" && !todo.DueDateValid {
 				warnings = append(warnings, fmt.Sprintf("%s:%d (%s)", rel, todo.LineNumber, todo.DueDateRaw))
 				s.logger.Warn("unrecognized due date", "path", rel, "line", todo.LineNumber, "value", todo.DueDateRaw)
 			}
-			tasks = append(tasks, task)
 		}
+		tasks = append(tasks, taskItemsFromTodos(rel, parsed)...)
 		return nil
 	})
 	if err != nil {
@@ -214,6 +235,78 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 	return tasks, notice, nil
 }
 
+func taskItemFromTodo(rel string, todo ParsedTodo) TaskItem {
+	parentID := ""
+	if todo.ParentLine > 0 {
+		parentID = fmt.Sprintf("%s:%d", rel, todo.ParentLine)
+	}
+	return TaskItem{
+		ID:         fmt.Sprintf("%s:%d", rel, todo.LineNumber),
+		Path:       rel,
+		LineNumber: todo.LineNumber,
+		LineHash:   todo.LineHash,
+		Text:       todo.Text,
+		Completed:  todo.Completed,
+		Project:    todo.Project,
+		Tags:       todo.Tags,
+		Mentions:   todo.Mentions,
+		DueDate:    todo.DueDateRaw,
+		DueDateISO: todo.DueDateISO,
+		Priority:   todo.Priority,
+		Recurrence: todo.Recurrence,
+		ParentID:   parentID,
+	}
+}
+
+// taskItemsFromTodos converts the tasks of one note and links subtasks to
+// their parents: a parent lists its direct children and gets progress over
+// every task nested below it.
+func taskItemsFromTodos(rel string, todos []ParsedTodo) []TaskItem {
+	items := make([]TaskItem, len(todos))
+	byLine := make(map[int]int, len(todos))
+	for i, todo := range todos {
+		items[i] = taskItemFromTodo(rel, todo)
+		byLine[todo.LineNumber] = i
+	}
+	for i, todo := range todos {
+		if todo.ParentLine == 0 {
+			continue
+		}
+		parent := &items[byLine[todo.ParentLine]]
+		parent.Children = append(parent.Children, items[i].ID)
+		for line := todo.ParentLine; line > 0; line = todos[byLine[line]].ParentLine {
+			ancestor := &items[byLine[line]]
+			if ancestor.Progress == nil {
+				ancestor.Progress = &TaskProgress{}
+			}
+			ancestor.Progress.Total++
+			if todo.Completed {
+				ancestor.Progress.Done++
+			}
+		}
+	}
+	return items
+}
+
+// findTaskLine returns the index of the task line the client means: the line
+// at lineNumber when its hash still matches, otherwise the first line with
+// that hash, so edits made above the task since it was listed do not matter.
+func findTaskLine(lines []string, lineNumber int, lineHash string) (int, bool) {
+	index := lineNumber - 1
+	if index >= 0 && index < len(lines) && lineHashMatches(lines[index], lineHash) {
+		return index, true
+	}
+	if lineHash == "" {
+		return 0, false
+	}
+	for i, line := range lines {
+		if lineHashMatches(line, lineHash) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 func lineHashMatches(line, hash string) bool {
 	if hash == "" {
 		return false
@@ -231,12 +324,22 @@ func (s *Server) archiveCompletedTasks() (int, int, error) {
 			return err
 		}
 		if d.IsDir() {
+			if s.isReservedDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if isIgnoredFile(d.Name()) || !isMarkdown(d.Name()) {
 			return nil
 		}
 
+		rel, err := filepath.Rel(s.notesDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		defer s.lockNote(rel)()
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return err
@@ -256,9 +359,11 @@ func (s *Server) archiveCompletedTasks() (int, int, error) {
 			return nil
 		}
 		output := strings.Join(lines, "\n")
-		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+		s.snapshotNote(rel, data, "archive")
+		if err := writeFileAtomic(path, []byte(output), 0o644); err != nil {
 			return err
 		}
+		s.indexPath(rel)
 		filesUpdated += 1
 		return nil
 	})
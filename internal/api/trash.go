@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const trashDirName = ".trash"
+
+type TrashItem struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	OriginalPath string    `json:"originalPath"`
+	Type         string    `json:"type"`
+	DeletedAt    time.Time `json:"deletedAt"`
+}
+
+type TrashRestorePayload struct {
+	ID   string `json:"id"`
+	Path string `json:"path,omitempty"`
+}
+
+type TrashPurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+func (s *Server) handleTrashList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.listTrash()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list trash")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[TrashRestorePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	id := strings.TrimSpace(payload.ID)
+	if !isSafeID(id) {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+
+	item, err := s.readTrashItem(id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "trash item not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read trash item")
+		return
+	}
+
+	target := item.OriginalPath
+	if strings.TrimSpace(payload.Path) != "" {
+		target = strings.TrimSpace(payload.Path)
+		if item.Type == "note" {
+			if isTemplate(item.Name) {
+				target = ensureTemplate(target)
+			} else {
+				target = ensureMarkdown(target)
+			}
+		}
+	}
+	absTarget, relTarget, err := s.resolvePath(target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if relTarget == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	if _, err := os.Stat(absTarget); err == nil {
+		writeError(w, http.StatusConflict, "destination already exists")
+		return
+	} else if !os.IsNotExist(err) {
+		writeError(w, http.StatusInternalServerError, "unable to check destination")
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(absTarget), 0o755); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to prepare destination")
+		return
+	}
+
+	if err := os.Rename(filepath.Join(s.trashDir(), item.ID, item.Name), absTarget); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to restore item")
+		return
+	}
+	s.moveHistoryDir(s.trashHistoryDir(item.ID), s.historyDir(relTarget))
+	if err := s.removeTrashItem(item.ID); err != nil {
+		s.logger.Warn("unable to clean trash item", "id", item.ID, "error", err)
+	}
+	s.indexPath(relTarget)
+
+	s.logger.Info("trash item restored", "id", item.ID, "path", relTarget)
+	writeJSON(w, http.StatusOK, map[string]string{"path": relTarget, "type": item.Type})
+}
+
+func (s *Server) handleTrashEmpty(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	id := strings.TrimSpace(query.Get("id"))
+	var cutoff time.Time
+	if raw := strings.TrimSpace(query.Get("olderThanDays")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			writeError(w, http.StatusBadRequest, "olderThanDays must be a non-negative integer")
+			return
+		}
+		cutoff = timeNow().AddDate(0, 0, -days)
+	}
+	if id != "" && !isSafeID(id) {
+		writeError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	items, err := s.listTrash()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list trash")
+		return
+	}
+
+	removed := 0
+	for _, item := range items {
+		if id != "" && item.ID != id {
+			continue
+		}
+		if !cutoff.IsZero() && item.DeletedAt.After(cutoff) {
+			continue
+		}
+		if err := s.removeTrashItem(item.ID); err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to empty trash")
+			return
+		}
+		removed += 1
+	}
+	if id != "" && removed == 0 {
+		writeError(w, http.StatusNotFound, "trash item not found")
+		return
+	}
+
+	s.logger.Info("trash emptied", "removed", removed, "id", id, "olderThanDays", query.Get("olderThanDays"))
+	writeJSON(w, http.StatusOK, TrashPurgeResponse{Removed: removed})
+}
+
+func (s *Server) trashDir() string {
+	return filepath.Join(s.notesDir, trashDirName)
+}
+
+// moveToTrash moves a note or folder into .trash/<id>/ and records where it
+// came from in .trash/<id>.json so it can be restored later. Its revisions
+// move to .trash/<id>.history/, so a new note at the same path starts with
+// no history and a restored one gets its own back.
+func (s *Server) moveToTrash(absPath, relPath, itemType string) (TrashItem, error) {
+	now := timeNow()
+	item := TrashItem{
+		ID:           now.UTC().Format("20060102T150405.000000000") + "-" + hashLine(relPath)[:8],
+		Name:         filepath.Base(absPath),
+		OriginalPath: relPath,
+		Type:         itemType,
+		DeletedAt:    now,
+	}
+
+	itemDir := filepath.Join(s.trashDir(), item.ID)
+	if err := os.MkdirAll(itemDir, 0o755); err != nil {
+		return TrashItem{}, err
+	}
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return TrashItem{}, err
+	}
+	data = append(data, '\n')
+	if err := writeFileAtomic(filepath.Join(s.trashDir(), item.ID+".json"), data, 0o644); err != nil {
+		_ = os.RemoveAll(itemDir)
+		return TrashItem{}, err
+	}
+	if err := os.Rename(absPath, filepath.Join(itemDir, item.Name)); err != nil {
+		_ = s.removeTrashItem(item.ID)
+		return TrashItem{}, err
+	}
+	s.moveHistoryDir(s.historyDir(relPath), s.trashHistoryDir(item.ID))
+	return item, nil
+}
+
+func (s *Server) trashHistoryDir(id string) string {
+	return filepath.Join(s.trashDir(), id+".history")
+}
+
+func (s *Server) listTrash() ([]TrashItem, error) {
+	entries, err := os.ReadDir(s.trashDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TrashItem{}, nil
+		}
+		return nil, err
+	}
+
+	items := make([]TrashItem, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		item, err := s.readTrashItem(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			s.logger.Warn("skipping unreadable trash item", "file", entry.Name(), "error", err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+	return items, nil
+}
+
+func (s *Server) readTrashItem(id string) (TrashItem, error) {
+	data, err := os.ReadFile(filepath.Join(s.trashDir(), id+".json"))
+	if err != nil {
+		return TrashItem{}, err
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return TrashItem{}, err
+	}
+	if item.ID != id || item.Name == "" || strings.ContainsAny(item.Name, `/\`) {
+		return TrashItem{}, fmt.Errorf("invalid trash metadata for %s", id)
+	}
+	return item, nil
+}
+
+func (s *Server) removeTrashItem(id string) error {
+	if err := os.RemoveAll(filepath.Join(s.trashDir(), id)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.trashHistoryDir(id)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.trashDir(), id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isSafeID reports whether id can be used as a single file name inside an
+// app-managed folder.
+func isSafeID(id string) bool {
+	id = strings.TrimSpace(id)
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -13,6 +18,8 @@ import (
 	"noldermd/internal/ui"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 type Config struct {
 	NotesDir string
 	Port     int
@@ -43,13 +50,40 @@ func Run(cfg Config) error {
 	}
 	logger.Info("server starting", "notesDir", notesDir, "port", cfg.Port)
 
+	apiServer := api.New(notesDir)
+	defer apiServer.Close()
+
 	r := chi.NewRouter()
 	r.Use(requestLogger)
-	r.Mount("/api/v1", api.NewRouter(notesDir))
+	r.Mount("/api/v1", apiServer.Routes())
 	r.Mount("/", ui.NewRouter())
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	return listenAndServe(addr, r)
 }
 
-var listenAndServe = http.ListenAndServe
+var listenAndServe = serveUntilSignal
+
+// serveUntilSignal serves handler on addr until SIGINT or SIGTERM, then lets
+// requests in flight finish before returning.
+func serveUntilSignal(addr string, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		slog.Info("server shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
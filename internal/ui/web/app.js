@@ -42,8 +42,10 @@ const settingsAutosaveInterval = document.getElementById("settings-autosave-inte
 const settingsDefaultFolder = document.getElementById("settings-default-folder");
 const settingsDailyFolder = document.getElementById("settings-daily-folder");
 const settingsShowTemplates = document.getElementById("settings-show-templates");
+const settingsRewriteDates = document.getElementById("settings-rewrite-dates");
 
 let currentNotePath = "";
+let currentNoteHash = "";
 let currentActivePath = "";
 let currentTree = null;
 let currentTags = [];
@@ -147,11 +149,58 @@ function renderMarkdown(text) {
   return marked.parse(text);
 }
 
+// Renders [[target]] and [[target|alias]] as wiki links. As an inline marked
+// extension it never sees code spans or fenced code, matching the server's
+// link parsing.
+const wikiLinkExtension = {
+  name: "wikiLink",
+  level: "inline",
+  start(src) {
+    const index = src.indexOf("[[");
+    return index < 0 ? undefined : index;
+  },
+  tokenizer(src) {
+    const match = /^\[\[([^\[\]\n]+)\]\]/.exec(src);
+    if (match) {
+      return { type: "wikiLink", raw: match[0], inner: match[1] };
+    }
+    return undefined;
+  },
+  renderer(token) {
+    const [target, alias] = token.inner.split("|");
+    const label = (alias || target).trim();
+    return `<a href="#" class="wiki-link" data-wiki-target="${escapeHtml(token.inner)}">${escapeHtml(label)}</a>`;
+  },
+};
+
+if (window.marked) {
+  marked.use({ extensions: [wikiLinkExtension] });
+}
+
+async function openWikiLink(target) {
+  try {
+    const query = `target=${encodeURIComponent(target)}&from=${encodeURIComponent(currentNotePath)}`;
+    const resolution = await apiFetch(`/links/resolve?${query}`);
+    if (resolution.status === "resolved") {
+      await openNote(resolution.path);
+      return;
+    }
+    if (resolution.status === "ambiguous") {
+      alert(`"${resolution.target}" matches several notes:\n${resolution.candidates.join("\n")}`);
+      return;
+    }
+    alert(`No note named "${resolution.target}".`);
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
 function extractTags(text) {
   if (!text) {
     return [];
   }
-  const pattern = /(^|\s)#([A-Za-z]+)\b/g;
+  // Matches the server's tag syntax: letters, digits, - and _, nested with /.
+  const pattern = /(^|\s)#([\p{L}\p{M}\p{N}_-]+(?:\/[\p{L}\p{M}\p{N}_-]+)*)/gu;
   const seen = new Set();
   const tags = [];
   let match;
@@ -378,6 +427,7 @@ function applySettings(settings) {
     defaultFolder: settings.defaultFolder || "",
     dailyFolder: settings.dailyFolder || "",
     showTemplates: settings.showTemplates !== false,
+    rewriteRelativeDates: !!settings.rewriteRelativeDates,
   };
   document.body.classList.toggle("theme-dark", currentSettings.darkMode);
   if (settingsDarkMode) {
@@ -401,6 +451,9 @@ function applySettings(settings) {
   if (settingsShowTemplates) {
     settingsShowTemplates.checked = currentSettings.showTemplates;
   }
+  if (settingsRewriteDates) {
+    settingsRewriteDates.checked = currentSettings.rewriteRelativeDates;
+  }
   applyAutosave(currentSettings);
   applySidebarWidth(currentSettings.sidebarWidth);
 }
@@ -452,6 +505,9 @@ function showSettings() {
   if (settingsShowTemplates) {
     settingsShowTemplates.checked = currentSettings.showTemplates;
   }
+  if (settingsRewriteDates) {
+    settingsRewriteDates.checked = currentSettings.rewriteRelativeDates;
+  }
 }
 
 async function saveSettings() {
@@ -462,7 +518,8 @@ async function saveSettings() {
     !settingsAutosaveInterval ||
     !settingsDefaultFolder ||
     !settingsDailyFolder ||
-    !settingsShowTemplates
+    !settingsShowTemplates ||
+    !settingsRewriteDates
   ) {
     return;
   }
@@ -479,6 +536,7 @@ async function saveSettings() {
       defaultFolder: settingsDefaultFolder.value.trim(),
       dailyFolder: settingsDailyFolder.value.trim(),
       showTemplates: settingsShowTemplates.checked,
+      rewriteRelativeDates: settingsRewriteDates.checked,
     };
     const updated = await apiFetch("/settings", {
       method: "PATCH",
@@ -534,9 +592,10 @@ function openTagGroup(tag) {
   if (!tagRow) {
     return;
   }
-  const tagWrapper = tagRow.closest(".tree-node.tag-group");
-  if (tagWrapper) {
+  let tagWrapper = tagRow.closest(".tree-node.tag-group");
+  while (tagWrapper) {
     tagWrapper.classList.remove("collapsed");
+    tagWrapper = tagWrapper.parentElement.closest(".tree-node.tag-group");
   }
   tagRow.scrollIntoView({ block: "center" });
 }
@@ -560,7 +619,10 @@ async function apiFetch(path, options = {}) {
 
   if (!response.ok) {
     const error = await response.json().catch(() => ({ error: "Request failed" }));
-    throw new Error(error.error || "Request failed");
+    const failure = new Error(error.error || "Request failed");
+    failure.status = response.status;
+    failure.data = error;
+    throw failure;
   }
 
   if (response.status === 204) {
@@ -587,6 +649,10 @@ function buildTreeNode(node, depth = 0) {
     const icon = document.createElement("span");
     icon.className = "folder-icon";
     row.appendChild(icon);
+  } else if (node.type === "search") {
+    const icon = document.createElement("span");
+    icon.className = "search-icon";
+    row.appendChild(icon);
   } else if (node.type === "asset") {
     const icon = document.createElement("span");
     icon.className = "asset-icon";
@@ -612,6 +678,47 @@ function buildTreeNode(node, depth = 0) {
 
   wrapper.appendChild(row);
 
+  if (node.type === "search") {
+    wrapper.classList.add("collapsed");
+    const children = document.createElement("div");
+    children.className = "node-children";
+    wrapper.appendChild(children);
+
+    row.addEventListener("click", async () => {
+      hideContextMenu();
+      wrapper.classList.toggle("collapsed");
+      if (wrapper.classList.contains("collapsed")) {
+        return;
+      }
+      // Saved searches are computed each time they are opened.
+      try {
+        const result = await apiFetch(`/tree?search=${encodeURIComponent(savedSearchId(node.path))}`);
+        children.innerHTML = "";
+        (result.children || []).forEach((child) => {
+          children.appendChild(buildTreeNode(child, depth + 1));
+        });
+        setActiveNode(currentActivePath);
+      } catch (err) {
+        alert(err.message);
+      }
+    });
+
+    row.addEventListener("contextmenu", (event) => {
+      event.preventDefault();
+      showContextMenu(event.clientX, event.clientY, [
+        {
+          label: "Rename",
+          action: () => renameSavedSearch(node),
+        },
+        {
+          label: "Delete",
+          action: () => deleteSavedSearch(node),
+        },
+      ]);
+    });
+    return wrapper;
+  }
+
   if (node.type === "folder") {
     if (depth > 0) {
       wrapper.classList.add("collapsed");
@@ -1105,6 +1212,12 @@ function buildTaskListItem(task) {
     const invalid = task.dueDate && !task.dueDateISO;
     meta.appendChild(buildTaskChip(label, invalid));
   }
+  if (task.recurrence) {
+    meta.appendChild(buildTaskChip(`*every:${task.recurrence}`));
+  }
+  if (task.progress) {
+    meta.appendChild(buildTaskChip(`${task.progress.done}/${task.progress.total}`));
+  }
   if (task.priority) {
     meta.appendChild(buildTaskChip(`^${task.priority}`));
   }
@@ -1136,6 +1249,9 @@ function buildTaskChip(text, invalid = false) {
 }
 
 async function toggleTaskCompletion(task, completed) {
+  const openSubtasks = task.progress ? task.progress.total - task.progress.done : 0;
+  const cascade =
+    completed && openSubtasks > 0 && confirm(`Also complete ${openSubtasks} open subtask(s)?`);
   try {
     await apiFetch("/tasks/toggle", {
       method: "PATCH",
@@ -1144,6 +1260,7 @@ async function toggleTaskCompletion(task, completed) {
         lineNumber: task.lineNumber,
         lineHash: task.lineHash,
         completed,
+        cascade,
       }),
     });
     await loadTree();
@@ -1164,6 +1281,34 @@ async function archiveCompletedTasks() {
   }
 }
 
+function flattenTagGroups(groups) {
+  const flat = [];
+  (groups || []).forEach((group) => {
+    flat.push(group);
+    flat.push(...flattenTagGroups(group.children));
+  });
+  return flat;
+}
+
+function showTagSummary(tags) {
+  const groups = flattenTagGroups(tags).filter((group) => (group.notes || []).length > 0);
+  const noteSet = new Set();
+  let totalEntries = 0;
+  groups.forEach((group) => {
+    group.notes.forEach((note) => {
+      if (note && note.path) {
+        noteSet.add(note.path);
+      }
+      totalEntries += 1;
+    });
+  });
+  showSummary("Tags", [
+    { label: "Tags", value: groups.length },
+    { label: "Tagged Notes", value: noteSet.size },
+    { label: "Tag Entries", value: totalEntries },
+  ]);
+}
+
 function buildTagRoot(tags) {
   const wrapper = document.createElement("div");
   wrapper.className = "tree-node folder tag-root collapsed";
@@ -1195,24 +1340,9 @@ function buildTagRoot(tags) {
   row.addEventListener("click", () => {
     hideContextMenu();
     wrapper.classList.toggle("collapsed");
-    const totalTags = (tags || []).length;
-    const noteSet = new Set();
-    let totalEntries = 0;
-    (tags || []).forEach((group) => {
-      (group.notes || []).forEach((note) => {
-        if (note && note.path) {
-          noteSet.add(note.path);
-        }
-        totalEntries += 1;
-      });
-    });
     currentActivePath = "__tags__";
     setActiveNode(currentActivePath);
-    showSummary("Tags", [
-      { label: "Tags", value: totalTags },
-      { label: "Tagged Notes", value: noteSet.size },
-      { label: "Tag Entries", value: totalEntries },
-    ]);
+    showTagSummary(currentTags);
   });
 
   row.addEventListener("contextmenu", (event) => {
@@ -1247,14 +1377,25 @@ function buildTagGroup(group, depth) {
 
   const name = document.createElement("span");
   name.className = "node-name tag-label";
-  name.textContent = `#${group.tag}`;
+  name.textContent = `#${depth > 1 ? group.name : group.tag}`;
+  name.title = `#${group.tag}`;
   name.style.backgroundColor = getTagColor(group.tag);
   row.appendChild(name);
 
+  if (group.count) {
+    const count = document.createElement("span");
+    count.className = "tag-count";
+    count.textContent = String(group.count);
+    row.appendChild(count);
+  }
+
   wrapper.appendChild(row);
 
   const children = document.createElement("div");
   children.className = "node-children";
+  (group.children || []).forEach((child) => {
+    children.appendChild(buildTagGroup(child, depth + 1));
+  });
   (group.notes || []).forEach((note) => {
     children.appendChild(buildTagNote(note, depth + 1));
   });
@@ -1263,24 +1404,9 @@ function buildTagGroup(group, depth) {
   row.addEventListener("click", () => {
     hideContextMenu();
     wrapper.classList.toggle("collapsed");
-    const totalTags = (tags || []).length;
-    const noteSet = new Set();
-    let totalEntries = 0;
-    (tags || []).forEach((group) => {
-      (group.notes || []).forEach((note) => {
-        if (note && note.path) {
-          noteSet.add(note.path);
-        }
-        totalEntries += 1;
-      });
-    });
     currentActivePath = "__tags__";
     setActiveNode(currentActivePath);
-    showSummary("Tags", [
-      { label: "Tags", value: totalTags },
-      { label: "Tagged Notes", value: noteSet.size },
-      { label: "Tag Entries", value: totalEntries },
-    ]);
+    showTagSummary(currentTags);
   });
 
   row.addEventListener("contextmenu", (event) => {
@@ -1455,6 +1581,7 @@ async function openNote(path) {
     showNoteEditor();
     const data = await apiFetch(`/notes?path=${encodeURIComponent(path)}`);
     currentNotePath = data.path;
+    currentNoteHash = data.hash || "";
     currentActivePath = data.path;
     notePath.textContent = data.path;
     editor.value = data.content;
@@ -1718,23 +1845,56 @@ async function saveNote() {
   try {
     saveBtn.disabled = true;
     saveBtn.textContent = "Saving...";
-    await apiFetch("/notes", {
+    const content = editor.value;
+    const result = await apiFetch("/notes", {
       method: "PATCH",
       body: JSON.stringify({
         path: currentNotePath,
-        content: editor.value,
+        content,
+        baseHash: currentNoteHash,
       }),
     });
+    currentNoteHash = result.hash || "";
+    // The server returns the content when it rewrote relative due dates.
+    if (typeof result.content === "string" && editor.value === content) {
+      editor.value = result.content;
+      preview.innerHTML = renderMarkdown(result.content);
+      applyHighlighting();
+    }
     isDirty = false;
     saveBtn.textContent = "Save";
     saveBtn.disabled = false;
   } catch (err) {
     saveBtn.textContent = "Save";
     saveBtn.disabled = false;
+    if (err.status === 409 && err.data) {
+      resolveSaveConflict(err.data);
+      return;
+    }
     alert(err.message);
   }
 }
 
+function resolveSaveConflict(conflict) {
+  const diff = conflict.diff || {};
+  const separate = diff.base && !diff.overlap ? " Your edits are in a different part of the note." : "";
+  const overwrite = confirm(
+    `${conflict.path} changed on the server since it was opened (around line ${diff.startLine}).${separate}\n\n` +
+      "OK overwrites the server copy with your edits. Cancel reloads the server copy."
+  );
+  if (overwrite) {
+    currentNoteHash = conflict.serverHash;
+    saveNote();
+    return;
+  }
+  currentNoteHash = conflict.serverHash;
+  editor.value = conflict.serverContent;
+  preview.innerHTML = renderMarkdown(conflict.serverContent);
+  applyHighlighting();
+  renderTagBar(extractTags(conflict.serverContent));
+  isDirty = false;
+}
+
 function saveCurrent() {
   if (currentMode === "settings") {
     saveSettings();
@@ -1953,16 +2113,18 @@ async function renameNote(path) {
   const base = path.split("/").slice(0, -1).join("/");
   const newName = isTemplate ? ensureTemplateName(name) : ensureMarkdownName(name);
   const newPath = base ? `${base}/${newName}` : newName;
+  const updateLinks = isTemplate ? false : window.confirm("Update links to this note in other notes?");
   try {
     const data = await apiFetch("/notes/rename", {
       method: "PATCH",
-      body: JSON.stringify({ path, newPath }),
+      body: JSON.stringify({ path, newPath, updateLinks }),
     });
     currentActivePath = parentPathForPath(path);
     await loadTree();
     if (currentNotePath === path) {
       await openNote(data.newPath || newPath);
     }
+    alertSkippedLinks(data);
   } catch (err) {
     alert(err.message);
   }
@@ -1997,23 +2159,32 @@ async function renameFolder(path) {
   }
   const base = path.split("/").slice(0, -1).join("/");
   const newPath = base ? `${base}/${name}` : name;
+  const updateLinks = window.confirm("Update links to notes and files in this folder?");
   try {
-    await apiFetch("/folders", {
+    const data = await apiFetch("/folders", {
       method: "PATCH",
-      body: JSON.stringify({ path, newPath }),
+      body: JSON.stringify({ path, newPath, updateLinks }),
     });
     await loadTree();
+    alertSkippedLinks(data);
   } catch (err) {
     alert(err.message);
   }
 }
 
+function alertSkippedLinks(data) {
+  const skipped = (data && data.skipped) || [];
+  if (skipped.length) {
+    alert(`Links were not updated in notes that changed during the rename:\n${skipped.join("\n")}`);
+  }
+}
+
 async function deleteFolder(path) {
   if (!path) {
     alert("Root folder cannot be deleted.");
     return;
   }
-  const confirmDelete = window.confirm("Delete this folder and all of its contents?");
+  const confirmDelete = window.confirm("Move this folder and all of its contents to the trash?");
   if (!confirmDelete) {
     return;
   }
@@ -2027,11 +2198,63 @@ async function deleteFolder(path) {
   }
 }
 
+function savedSearchId(path) {
+  return String(path || "").replace(/^search:/, "");
+}
+
+async function saveSearch(query) {
+  const name = window.prompt("Save search as", query);
+  if (!name || !name.trim()) {
+    return;
+  }
+  try {
+    await apiFetch("/searches", {
+      method: "POST",
+      body: JSON.stringify({ name: name.trim(), query }),
+    });
+    hideSearchResults();
+    await loadTree();
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
+async function renameSavedSearch(node) {
+  const name = window.prompt("Rename saved search", node.name);
+  if (!name || !name.trim() || name.trim() === node.name) {
+    return;
+  }
+  try {
+    await apiFetch("/searches", {
+      method: "PATCH",
+      body: JSON.stringify({ id: savedSearchId(node.path), name: name.trim() }),
+    });
+    await loadTree();
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
+async function deleteSavedSearch(node) {
+  const confirmDelete = window.confirm(`Delete the saved search "${node.name}"?`);
+  if (!confirmDelete) {
+    return;
+  }
+  try {
+    await apiFetch(`/searches?id=${encodeURIComponent(savedSearchId(node.path))}`, {
+      method: "DELETE",
+    });
+    await loadTree();
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
 async function deleteNote(path) {
   if (!path) {
     return;
   }
-  const confirmDelete = window.confirm("Delete this note?");
+  const confirmDelete = window.confirm("Move this note to the trash?");
   if (!confirmDelete) {
     return;
   }
@@ -2087,9 +2310,17 @@ function hideContextMenu() {
   contextMenu.classList.add("hidden");
 }
 
-function renderSearchResults(matches) {
+function renderSearchResults(matches, query) {
   const safeMatches = Array.isArray(matches) ? matches : [];
   searchResults.innerHTML = "";
+  if (query) {
+    const save = document.createElement("button");
+    save.type = "button";
+    save.className = "search-save";
+    save.textContent = "Save search…";
+    save.addEventListener("click", () => saveSearch(query));
+    searchResults.appendChild(save);
+  }
   if (safeMatches.length === 0) {
     const empty = document.createElement("div");
     empty.className = "search-empty";
@@ -2101,16 +2332,57 @@ function renderSearchResults(matches) {
     const button = document.createElement("button");
     button.type = "button";
     const rawName = match.name || match.path.split("/").pop();
-    button.textContent = displayNodeName({ type: "file", name: rawName });
+    const isTask = match.type === "task";
+    button.textContent = isTask ? `☐ ${rawName}` : displayNodeName({ type: "file", name: rawName });
     button.title = match.path;
     button.addEventListener("click", () => {
       hideSearchResults();
+      const firstLine = match.matches && match.matches[0];
+      if (isTask && firstLine) {
+        openNoteAtLine(match.path, firstLine.lineNumber);
+        return;
+      }
       openNote(match.path);
     });
+    if (match.matchCount) {
+      const count = document.createElement("span");
+      count.className = "search-count";
+      count.textContent = String(match.matchCount);
+      button.appendChild(count);
+    }
     searchResults.appendChild(button);
+    (match.matches || []).forEach((hit) => {
+      const line = document.createElement("button");
+      line.type = "button";
+      line.className = "search-snippet";
+      line.title = `${match.path}:${hit.lineNumber}`;
+      appendHighlightedSnippet(line, hit.snippet, hit.ranges);
+      line.addEventListener("click", () => {
+        hideSearchResults();
+        openNoteAtLine(match.path, hit.lineNumber);
+      });
+      searchResults.appendChild(line);
+    });
   });
 }
 
+function appendHighlightedSnippet(parent, snippet, ranges) {
+  // Ranges count characters (code points), so slice an array of them.
+  const chars = Array.from(snippet || "");
+  let cursor = 0;
+  (ranges || []).forEach((range) => {
+    if (range.start < cursor) {
+      return;
+    }
+    parent.appendChild(document.createTextNode(chars.slice(cursor, range.start).join("")));
+    const mark = document.createElement("mark");
+    mark.textContent = chars.slice(range.start, range.end).join("");
+    parent.appendChild(mark);
+    cursor = range.end;
+  });
+  parent.appendChild(document.createTextNode(chars.slice(cursor).join("")));
+}
+
 function showSearchResults() {
   searchResults.classList.remove("hidden");
 }
@@ -2127,7 +2399,7 @@ async function runSearch() {
   }
   try {
     const matches = await apiFetch(`/search?query=${encodeURIComponent(query)}`);
-    renderSearchResults(matches);
+    renderSearchResults(matches, query);
     showSearchResults();
   } catch (err) {
     alert(err.message);
@@ -2337,6 +2609,17 @@ if (settingsShowTemplates) {
   });
 }
 
+if (settingsRewriteDates) {
+  settingsRewriteDates.addEventListener("change", () => {
+    if (currentMode !== "settings") {
+      return;
+    }
+    currentSettings.rewriteRelativeDates = settingsRewriteDates.checked;
+    isDirty = true;
+    saveBtn.disabled = false;
+  });
+}
+
 function normalizeTagInput(value) {
   const trimmed = String(value || "").trim();
   if (!trimmed) {
@@ -2413,6 +2696,14 @@ function markActiveScrollSource(source) {
 }
 
 editor.addEventListener("wheel", () => markActiveScrollSource(editor), { passive: true });
+preview.addEventListener("click", (event) => {
+  const link = event.target.closest("a.wiki-link");
+  if (!link) {
+    return;
+  }
+  event.preventDefault();
+  openWikiLink(link.dataset.wikiTarget);
+});
 preview.addEventListener("wheel", () => markActiveScrollSource(preview), { passive: true });
 editor.addEventListener("touchstart", () => markActiveScrollSource(editor), { passive: true });
 preview.addEventListener("touchstart", () => markActiveScrollSource(preview), { passive: true });